@@ -0,0 +1,76 @@
+package fileio
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AtomicFile write into a temporary file in the same directory as target
+// and replace target only on Commit, so target is always old or new version.
+type AtomicFile struct {
+	*os.File
+	target string
+}
+
+// Create temporary file next to target, same directory keep rename atomic
+func CreateAtomic(target string) (*AtomicFile, error) {
+	dir, name := filepath.Split(target)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep original permissions if target exists
+	if info, err := os.Stat(target); err == nil {
+		f.Chmod(info.Mode())
+	}
+
+	return &AtomicFile{File: f, target: target}, nil
+}
+
+// Flush written data to disk and rename temporary file over target
+func (a *AtomicFile) Commit() error {
+	if err := a.Sync(); err != nil {
+		a.Abort()
+		return err
+	}
+
+	if err := a.Close(); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+
+	if err := os.Rename(a.Name(), a.target); err != nil {
+		os.Remove(a.Name())
+		return err
+	}
+
+	// Persist rename in directory entry
+	return syncDir(filepath.Dir(a.target))
+}
+
+// Discard temporary file leaving target untouched
+func (a *AtomicFile) Abort() error {
+	a.Close()
+	return os.Remove(a.Name())
+}
+
+// Fsync directory to persist entries, not supported on windows
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	defer d.Close()
+	return d.Sync()
+}
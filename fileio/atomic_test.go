@@ -0,0 +1,82 @@
+package fileio
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Check only the given file exist in directory, no temporary leftovers
+func checkClean(t *testing.T, dir string) {
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left in directory, got %d entries", len(entries))
+	}
+}
+
+func TestAtomicCommit(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	os.WriteFile(target, []byte("old version"), 0600)
+
+	out, err := CreateAtomic(target)
+	if err != nil {
+		t.Fatalf("Expected atomic file created, got %v", err)
+	}
+
+	out.Write([]byte("new version"))
+	if err := out.Commit(); err != nil {
+		t.Fatalf("Expected commit without errors, got %v", err)
+	}
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "new version" {
+		t.Errorf("Expected new version after commit")
+	}
+
+	info, _ := os.Stat(target)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected original permissions kept after commit")
+	}
+
+	checkClean(t, dir)
+}
+
+func TestAtomicInterrupted(t *testing.T) {
+	original := []byte("i am here guys how are you doing this is a small test")
+	target := []byte("i am here guys how are you doingadded this is a small test")
+
+	// Simulate crash after any amount of written bytes
+	for cut := 0; cut <= len(target); cut++ {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "target.txt")
+		os.WriteFile(file, original, 0644)
+
+		out, _ := CreateAtomic(file)
+		out.Write(target[:cut])
+		out.Close() // process die before commit
+
+		content, _ := os.ReadFile(file)
+		if !bytes.Equal(content, original) {
+			t.Fatalf("Expected original intact after interruption at byte %d", cut)
+		}
+	}
+}
+
+func TestAtomicAbort(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	os.WriteFile(target, []byte("old version"), 0644)
+
+	out, _ := CreateAtomic(target)
+	out.Write([]byte("new"))
+	out.Abort()
+
+	content, _ := os.ReadFile(target)
+	if string(content) != "old version" {
+		t.Errorf("Expected old version after abort")
+	}
+
+	checkClean(t, dir)
+}
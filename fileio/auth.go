@@ -0,0 +1,184 @@
+package fileio
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Trailer appended to signed files after the authentication tag:
+// tag length (uint32) + magic
+var signedMagic = []byte("RSAUTH01")
+
+const trailerSize = 4 + 8
+
+// Max tag size accepted, bound reads from untrusted trailers
+const maxTagSize = 1 << 10
+
+// Authenticator sign and verify SHA-256 digest of file contents
+type Authenticator interface {
+	Sign(digest []byte) ([]byte, error)
+	Verify(digest, tag []byte) bool
+}
+
+// ErrUnauthenticated is returned when a file is not signed or its tag
+// does not match contents, file must not be used.
+type ErrUnauthenticated struct {
+	File   string
+	Reason string
+}
+
+func (e *ErrUnauthenticated) Error() string {
+	return fmt.Sprintf("unauthenticated file %s: %s", e.File, e.Reason)
+}
+
+// HMAC-SHA256 authenticator using a key shared by both sides
+type hmacAuth struct {
+	key []byte
+}
+
+// Factory function for HMAC-SHA256 authenticator
+func NewHMAC(key []byte) Authenticator {
+	return hmacAuth{key: key}
+}
+
+func (a hmacAuth) Sign(digest []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write(digest)
+	return mac.Sum(nil), nil
+}
+
+func (a hmacAuth) Verify(digest, tag []byte) bool {
+	expected, _ := a.Sign(digest)
+	return hmac.Equal(expected, tag)
+}
+
+// Ed25519 authenticator, receivers only need the public key
+type ed25519Auth struct {
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+}
+
+// Factory function for Ed25519 authenticator.
+// Private key could be nil to only verify files.
+func NewEd25519(public ed25519.PublicKey, private ed25519.PrivateKey) Authenticator {
+	return ed25519Auth{public: public, private: private}
+}
+
+func (a ed25519Auth) Sign(digest []byte) ([]byte, error) {
+	if len(a.private) != ed25519.PrivateKeySize {
+		return nil, errors.New("no ed25519 private key to sign")
+	}
+
+	return ed25519.Sign(a.private, digest), nil
+}
+
+func (a ed25519Auth) Verify(digest, tag []byte) bool {
+	return len(a.public) == ed25519.PublicKeySize && ed25519.Verify(a.public, digest, tag)
+}
+
+// Write file with contents encoded by encode, followed by authentication tag
+func writeSigned(file string, auth Authenticator, encode func(io.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	digest := sha256.New()
+	if err := encode(io.MultiWriter(f, digest)); err != nil {
+		return err
+	}
+
+	tag, err := auth.Sign(digest.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	trailer := make([]byte, 4, 4+len(tag)+len(signedMagic))
+	binary.BigEndian.PutUint32(trailer, uint32(len(tag)))
+	trailer = append(append(tag, trailer...), signedMagic...)
+	_, err = f.Write(trailer)
+	return err
+}
+
+// Read signed file verifying authentication tag before any content is used.
+// Return file contents without trailer, verified bytes are the ones decoded
+// so file changes after verification can't get in.
+func readSigned(file string, auth Authenticator) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int64(len(data))
+	if size < trailerSize {
+		return nil, &ErrUnauthenticated{File: file, Reason: "file is not signed"}
+	}
+
+	trailer := data[size-trailerSize:]
+	if !bytes.Equal(trailer[4:], signedMagic) {
+		return nil, &ErrUnauthenticated{File: file, Reason: "file is not signed"}
+	}
+
+	tagSize := int64(binary.BigEndian.Uint32(trailer))
+	if tagSize > maxTagSize || tagSize > size-trailerSize {
+		return nil, &ErrUnauthenticated{File: file, Reason: "invalid tag size"}
+	}
+
+	payloadSize := size - trailerSize - tagSize
+	digest := sha256.Sum256(data[:payloadSize])
+	if !auth.Verify(digest[:], data[payloadSize:size-trailerSize]) {
+		return nil, &ErrUnauthenticated{File: file, Reason: "tag mismatch"}
+	}
+
+	return data[:payloadSize], nil
+}
+
+// Write signature followed by authentication tag
+func WriteSignedSignature(file string, signatures sync.Signature, auth Authenticator) error {
+	if len(signatures.Tables) == 0 {
+		return errors.New("no signatures to write")
+	}
+
+	return writeSigned(file, auth, func(w io.Writer) error {
+		return encodeSignature(w, signatures)
+	})
+}
+
+// Read signature verifying authentication tag first.
+// Return *ErrUnauthenticated if file is not signed or was tampered.
+func ReadSignedSignature(file string, auth Authenticator) (sync.Signature, error) {
+	payload, err := readSigned(file, auth)
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	return decodeSignature(bytes.NewReader(payload))
+}
+
+// Write delta stream followed by authentication tag
+func WriteSignedDelta(file string, delta sync.Stream, auth Authenticator) error {
+	return writeSigned(file, auth, func(w io.Writer) error {
+		return EncodeDelta(w, delta, sync.Checkpoint{})
+	})
+}
+
+// Read delta stream verifying authentication tag first.
+// Return *ErrUnauthenticated if file is not signed or was tampered.
+func ReadSignedDelta(file string, auth Authenticator) (sync.Stream, error) {
+	payload, err := readSigned(file, auth)
+	if err != nil {
+		return sync.Stream{}, err
+	}
+
+	return decodeDelta(bytes.NewReader(payload))
+}
@@ -0,0 +1,116 @@
+package fileio
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Return authenticators able to sign, and verifiers for them
+func mockAuthenticators(t *testing.T) map[string][2]Authenticator {
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmac := NewHMAC([]byte("shared secret"))
+	return map[string][2]Authenticator{
+		"hmac":    {hmac, hmac},
+		"ed25519": {NewEd25519(public, private), NewEd25519(public, nil)},
+	}
+}
+
+// Flip a byte in file at offset from start, negative offsets count from end
+func tamper(t *testing.T, file string, offset int) {
+	data, _ := os.ReadFile(file)
+	if offset < 0 {
+		offset += len(data)
+	}
+
+	data[offset] ^= 0xff
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSignedReadWrite(t *testing.T) {
+	dir := t.TempDir()
+	delta := mockDelta(t)
+	signatures := sync.Signature{BlockSize: 4, Checksum: "abc123", Tables: []sync.Table{{Weak: 1, Strong: "abc123"}}}
+
+	for name, auth := range mockAuthenticators(t) {
+		sigFile := filepath.Join(dir, name+".sig")
+		deltaFile := filepath.Join(dir, name+".delta")
+		if err := WriteSignedSignature(sigFile, signatures, auth[0]); err != nil {
+			t.Fatalf("Expected %s signed signature written, got %v", name, err)
+		}
+
+		if err := WriteSignedDelta(deltaFile, delta, auth[0]); err != nil {
+			t.Fatalf("Expected %s signed delta written, got %v", name, err)
+		}
+
+		sig, err := ReadSignedSignature(sigFile, auth[1])
+		if err != nil || !reflect.DeepEqual(sig, signatures) {
+			t.Errorf("Expected %s signed signature read back, got %v", name, err)
+		}
+
+		read, err := ReadSignedDelta(deltaFile, auth[1])
+		if err != nil || !reflect.DeepEqual(read, delta) {
+			t.Errorf("Expected %s signed delta read back, got %v", name, err)
+		}
+	}
+}
+
+func TestSignedTampered(t *testing.T) {
+	dir := t.TempDir()
+	delta := mockDelta(t)
+
+	for name, auth := range mockAuthenticators(t) {
+		// Contents, tag and trailer changes must be detected
+		for _, offset := range []int{0, 40, -trailerSize - 1, -1} {
+			file := filepath.Join(dir, name+".delta")
+			WriteSignedDelta(file, delta, auth[0])
+			tamper(t, file, offset)
+
+			var unauthenticated *ErrUnauthenticated
+			if _, err := ReadSignedDelta(file, auth[1]); !errors.As(err, &unauthenticated) {
+				t.Errorf("Expected %s tampered delta at %d to fail authentication, got %v", name, offset, err)
+			}
+		}
+	}
+}
+
+func TestSignedWrongKey(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "delta.bin")
+	WriteSignedDelta(file, mockDelta(t), NewHMAC([]byte("shared secret")))
+
+	var unauthenticated *ErrUnauthenticated
+	if _, err := ReadSignedDelta(file, NewHMAC([]byte("other secret"))); !errors.As(err, &unauthenticated) {
+		t.Errorf("Expected delta signed with other key to fail authentication, got %v", err)
+	}
+
+	public, _, _ := ed25519.GenerateKey(nil)
+	if _, err := ReadSignedDelta(file, NewEd25519(public, nil)); !errors.As(err, &unauthenticated) {
+		t.Errorf("Expected delta signed with hmac to fail ed25519 authentication, got %v", err)
+	}
+}
+
+func TestSignedUnsigned(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "delta.bin")
+	WriteDelta(file, mockDelta(t))
+
+	var unauthenticated *ErrUnauthenticated
+	if _, err := ReadSignedDelta(file, NewHMAC([]byte("shared secret"))); !errors.As(err, &unauthenticated) {
+		t.Errorf("Expected unsigned delta to fail authentication, got %v", err)
+	}
+
+	public, _, _ := ed25519.GenerateKey(nil)
+	if err := WriteSignedDelta(file, mockDelta(t), NewEd25519(public, nil)); err == nil {
+		t.Errorf("Expected error signing without ed25519 private key")
+	}
+}
@@ -0,0 +1,178 @@
+package fileio
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Cache database file name under cache directory
+const cacheFile = "signatures"
+
+// Files modified this close to hashing are not cached, a later change
+// within the same mtime tick would not be noticed
+const racyWindow = 2 * time.Second
+
+// Cached signature and identity of file it was built from
+type cacheEntry struct {
+	Size      int64
+	ModTime   time.Time
+	Device    uint64
+	Inode     uint64
+	Signature sync.Signature
+}
+
+// Cache keep the last signature built for each file in a single file under cache
+// directory, keyed by path and invalidated when file size, mtime or inode change.
+// Signature is reused only for the same block size, seeded ones are not cached.
+type Cache struct {
+	file    string
+	entries map[string]cacheEntry // Keyed by absolute path
+}
+
+// Open signature cache under directory, creating it if needed
+func OpenCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{file: filepath.Join(dir, cacheFile), entries: make(map[string]cacheEntry)}
+	f, err := os.Open(c.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	// Broken cache is discarded, it will be rebuilt
+	if err := gob.NewDecoder(f).Decode(&c.entries); err != nil {
+		c.entries = make(map[string]cacheEntry)
+	}
+
+	return c, nil
+}
+
+// Return cache entry identifying file
+func identify(info os.FileInfo) cacheEntry {
+	device, inode := fileID(info)
+	return cacheEntry{Size: info.Size(), ModTime: info.ModTime(), Device: device, Inode: inode}
+}
+
+// Return true if both entries identify the same unchanged file
+func (e cacheEntry) same(other cacheEntry) bool {
+	return e.Size == other.Size && e.ModTime.Equal(other.ModTime) &&
+		e.Device == other.Device && e.Inode == other.Inode
+}
+
+// Return absolute path and current identity of file
+func stat(file string) (string, cacheEntry, error) {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return "", cacheEntry{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", cacheEntry{}, err
+	}
+
+	return path, identify(info), nil
+}
+
+// Return cached entry for path if file is unchanged and it was signed with block size
+func (c *Cache) cached(path string, current cacheEntry, blockSize int) (cacheEntry, bool) {
+	entry, ok := c.entries[path]
+	return entry, ok && entry.same(current) && entry.Signature.BlockSize == blockSize
+}
+
+// Return signature for file, from cache if file is unchanged since it was built.
+// Zero block size pick it from file size. Seed could be nil, see sync.WithSeed,
+// seeded signatures are never cached so a seed is not reused across sessions.
+func (c *Cache) Signature(file string, blockSize int, seed []byte) (sync.Signature, error) {
+	path, current, err := stat(file)
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	blockSize = New(blockSize).BlockSize(current.Size)
+	if seed != nil {
+		return buildSignature(path, blockSize, seed)
+	}
+
+	if entry, ok := c.cached(path, current, blockSize); ok {
+		return entry.Signature, nil
+	}
+
+	start := time.Now()
+	sig, err := buildSignature(path, blockSize, seed)
+	if err != nil {
+		return sig, err
+	}
+
+	// Keep only if file did not change while hashing and it is not racy
+	after, err := os.Stat(path)
+	if err != nil || !identify(after).same(current) || start.Sub(current.ModTime) < racyWindow {
+		delete(c.entries, path)
+		return sig, nil
+	}
+
+	current.Signature = sig
+	c.entries[path] = current
+	return sig, c.save()
+}
+
+// Build signature for file
+func buildSignature(path string, blockSize int, seed []byte) (sync.Signature, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	// Blocks inside holes are not read
+	found, err := holes(f, info.Size())
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	options := []sync.Option{sync.WithHoles(found)}
+	if seed != nil {
+		options = append(options, sync.WithSeed(seed))
+	}
+
+	return sync.New(blockSize, options...).BuildSigTable(bufio.NewReader(f)), nil
+}
+
+// Persist cache atomically, dropping entries of removed or changed files
+func (c *Cache) save() error {
+	for path, entry := range c.entries {
+		if info, err := os.Stat(path); err != nil || !entry.same(identify(info)) {
+			delete(c.entries, path)
+		}
+	}
+
+	f, err := CreateAtomic(c.file)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(c.entries); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
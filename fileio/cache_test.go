@@ -0,0 +1,156 @@
+package fileio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Write file with modification time old enough to be cached
+func writeOld(t *testing.T, file string, data string) time.Time {
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	os.Chtimes(file, old, old)
+	return old
+}
+
+// Return signature built from scratch
+func fullSignature(t *testing.T, file string, blockSize int) sync.Signature {
+	f, _ := os.Open(file)
+	defer f.Close()
+	return sync.New(blockSize).BuildSigTable(bufio.NewReader(f))
+}
+
+func TestCacheHit(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "basis.txt")
+	old := writeOld(t, file, "i am here guys how are you doing")
+
+	cache, _ := OpenCache(filepath.Join(dir, "cache"))
+	sig, err := cache.Signature(file, 8, nil)
+	if err != nil || !reflect.DeepEqual(sig, fullSignature(t, file, 8)) {
+		t.Fatalf("Expected signature built on miss, got %v", err)
+	}
+
+	// Same size and mtime change is not noticed, so cached signature is returned
+	writeOld(t, file, "I AM HERE GUYS HOW ARE YOU DOING")
+	os.Chtimes(file, old, old)
+
+	reopened, _ := OpenCache(filepath.Join(dir, "cache"))
+	cached, _ := reopened.Signature(file, 8, nil)
+	if !reflect.DeepEqual(cached, sig) {
+		t.Errorf("Expected persisted signature returned for unchanged file identity")
+	}
+
+	// Signature is reused only for same block size and seed
+	if other, _ := reopened.Signature(file, 4, nil); reflect.DeepEqual(other, sig) {
+		t.Errorf("Expected other block size signature built apart")
+	}
+
+	if seeded, _ := reopened.Signature(file, 8, []byte("seed")); reflect.DeepEqual(seeded, sig) {
+		t.Errorf("Expected seeded signature built apart")
+	}
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "basis.txt")
+	cache, _ := OpenCache(filepath.Join(dir, "cache"))
+
+	changes := map[string]func(){
+		"size":  func() { writeOld(t, file, "i am here guys how are you doing this") },
+		"mtime": func() { os.Chtimes(file, time.Now().Add(-time.Minute), time.Now().Add(-time.Minute)) },
+		"inode": func() {
+			// Replaced by rename keeping size and mtime
+			info, _ := os.Stat(file)
+			data, _ := os.ReadFile(file)
+			data[0] ^= 0x20
+			replacement := file + ".new"
+			os.WriteFile(replacement, data, 0644)
+			os.Chtimes(replacement, info.ModTime(), info.ModTime())
+			os.Rename(replacement, file)
+		},
+	}
+
+	for _, name := range []string{"size", "mtime", "inode"} {
+		writeOld(t, file, "i am here guys how are you doing")
+		cache.Signature(file, 8, nil)
+		changes[name]()
+
+		sig, _ := cache.Signature(file, 8, nil)
+		if !reflect.DeepEqual(sig, fullSignature(t, file, 8)) {
+			t.Errorf("Expected cached signature invalidated on %s change", name)
+		}
+	}
+}
+
+func TestCacheRacy(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "basis.txt")
+	os.WriteFile(file, []byte("i am here guys how are you doing"), 0644)
+
+	cache, _ := OpenCache(filepath.Join(dir, "cache"))
+	cache.Signature(file, 8, nil)
+	if len(cache.entries) != 0 {
+		t.Errorf("Expected just modified file not cached")
+	}
+}
+
+func TestCacheSeeded(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "basis.txt")
+	writeOld(t, file, "i am here guys how are you doing")
+	cache, _ := OpenCache(filepath.Join(dir, "cache"))
+
+	// Seeded signature is built with the given seed and never stored
+	sig, err := cache.Signature(file, 8, []byte("seed"))
+	if err != nil || !bytes.Equal(sig.Seed, []byte("seed")) {
+		t.Fatalf("Expected signature built with seed, got %v", err)
+	}
+
+	if len(cache.entries) != 0 {
+		t.Errorf("Expected seeded signature not cached, got %d entries", len(cache.entries))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "cache", cacheFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected no cache file written for seeded signature, got %v", err)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	removed := filepath.Join(dir, "removed.txt")
+	file := filepath.Join(dir, "basis.txt")
+	writeOld(t, removed, "i am here guys how are you doing")
+	writeOld(t, file, "i am here guys how are you doing")
+
+	cache, _ := OpenCache(filepath.Join(dir, "cache"))
+	cache.Signature(removed, 8, nil)
+	os.Remove(removed)
+
+	// Only last signature of each existing file is kept
+	for _, size := range []int{4, 8, 16} {
+		cache.Signature(file, size, nil)
+	}
+
+	reopened, _ := OpenCache(filepath.Join(dir, "cache"))
+	if len(reopened.entries) != 1 {
+		t.Fatalf("Expected only last signature of existing file kept, got %d entries", len(reopened.entries))
+	}
+
+	for _, entry := range reopened.entries {
+		if entry.Signature.BlockSize != 16 {
+			t.Errorf("Expected last signature kept, got block size %d", entry.Signature.BlockSize)
+		}
+	}
+}
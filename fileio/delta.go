@@ -0,0 +1,170 @@
+package fileio
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Delta stream record kinds
+const (
+	recordOp uint8 = iota + 1
+	recordCheckpoint
+	recordEnd
+)
+
+// Delta stream header, From is the operation index the stream start at
+type header struct {
+	Checksum    string
+	Compression sync.Compression
+	From        int
+}
+
+// Delta stream record, an operation, a checkpoint or the stream end
+type record struct {
+	Kind       uint8
+	Op         sync.Op
+	Checkpoint sync.Checkpoint
+}
+
+// Encode delta as a stream of records starting right after checkpoint,
+// so an interrupted transfer could be resumed from the last verified checkpoint.
+// Use zero Checkpoint to encode the complete delta.
+func EncodeDelta(w io.Writer, delta sync.Stream, from sync.Checkpoint) error {
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(header{Checksum: delta.Checksum, Compression: delta.Compression, From: from.Op}); err != nil {
+		return err
+	}
+
+	checkpoints := delta.Checkpoints
+	for i := from.Op; i <= len(delta.Ops); i++ {
+		// Checkpoints reached before next operation
+		for len(checkpoints) > 0 && checkpoints[0].Op <= i {
+			if checkpoints[0].Op == i && i > from.Op {
+				err := enc.Encode(record{Kind: recordCheckpoint, Checkpoint: checkpoints[0]})
+				if err != nil {
+					return err
+				}
+			}
+
+			checkpoints = checkpoints[1:]
+		}
+
+		if i == len(delta.Ops) {
+			break
+		}
+
+		if err := enc.Encode(record{Kind: recordOp, Op: delta.Ops[i]}); err != nil {
+			return err
+		}
+	}
+
+	return enc.Encode(record{Kind: recordEnd})
+}
+
+// Apply delta stream over source resuming from checkpoint, out must continue right
+// after checkpoint offset. Return last verified checkpoint, so if stream get cut
+// the output could be truncated to checkpoint offset and patching resumed from there.
+func PatchStream(source io.ReaderAt, r io.Reader, out io.Writer, from sync.Checkpoint) (sync.Checkpoint, error) {
+	var head header
+	dec := gob.NewDecoder(r)
+	if err := dec.Decode(&head); err != nil {
+		return from, err
+	}
+
+	if head.From != from.Op {
+		return from, errors.New("delta stream does not start at checkpoint")
+	}
+
+	patcher, err := sync.NewPatcher(source, out, from, head.Compression)
+	if err != nil {
+		return from, err
+	}
+
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			return patcher.Last(), err
+		}
+
+		switch rec.Kind {
+		case recordOp:
+			err = patcher.Apply(rec.Op)
+		case recordCheckpoint:
+			// Persist output before marking checkpoint as verified
+			if f, ok := out.(interface{ Sync() error }); ok {
+				if err := f.Sync(); err != nil {
+					return patcher.Last(), err
+				}
+			}
+
+			err = patcher.Checkpoint(rec.Checkpoint)
+		case recordEnd:
+			return patcher.Last(), patcher.Verify(head.Checksum)
+		default:
+			err = errors.New("unknown delta stream record")
+		}
+
+		if err != nil {
+			return patcher.Last(), err
+		}
+	}
+}
+
+// Write delta stream to file
+// Return error if file creation fail or encode delta fail
+func WriteDelta(file string, delta sync.Stream) error {
+	//  Performed writing operations
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	return EncodeDelta(f, delta, sync.Checkpoint{})
+}
+
+// Read delta stream from file and decode it
+// Return error if file reading fail or decode delta fail
+func ReadDelta(file string) (sync.Stream, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return sync.Stream{}, err
+	}
+
+	defer f.Close()
+	return decodeDelta(f)
+}
+
+// Decode complete delta stream from reader
+func decodeDelta(r io.Reader) (sync.Stream, error) {
+	var read sync.Stream
+	var head header
+	dec := gob.NewDecoder(r)
+	if err := dec.Decode(&head); err != nil {
+		return read, err
+	}
+
+	read.Checksum = head.Checksum
+	read.Compression = head.Compression
+	for {
+		var rec record
+		if err := dec.Decode(&rec); err != nil {
+			return read, err
+		}
+
+		switch rec.Kind {
+		case recordOp:
+			read.Ops = append(read.Ops, rec.Op)
+		case recordCheckpoint:
+			read.Checkpoints = append(read.Checkpoints, rec.Checkpoint)
+		case recordEnd:
+			return read, nil
+		default:
+			return read, errors.New("unknown delta stream record")
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package fileio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+func TestDeltaReadWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "delta.bin")
+	delta := sync.Stream{
+		Checksum: "abc123",
+		Ops: []sync.Op{
+			{Type: sync.OpLiteral, Lit: []byte("added")},
+			{Type: sync.OpCopy, Index: 1, Start: 16, Offset: 32},
+		},
+	}
+
+	if err := WriteDelta(file, delta); err != nil {
+		t.Fatalf("Expected delta written without errors, got %v", err)
+	}
+
+	out, _ := ReadDelta(file)
+	if !reflect.DeepEqual(delta, out) {
+		t.Errorf("Expected written delta equal to out delta")
+	}
+}
+
+func TestDeltaBadFileRead(t *testing.T) {
+	_, err := ReadDelta("notexists.bin")
+
+	if err == nil {
+		t.Error("Expected error with invalid file to read")
+	}
+}
+
+func TestDeltaBadDataRead(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "invalid.bin")
+	os.WriteFile(file, []byte("I am invalid gob"), 0644)
+	_, err := ReadDelta(file)
+
+	if err == nil {
+		t.Error("Expected error with invalid file gob data content")
+	}
+}
+
+func TestPatchStreamResume(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 16)
+	b := bytes.Replace(a, []byte("small"), []byte("big"), 5)
+
+	s := sync.New(1<<4, sync.WithCheckpoint(1<<6))
+	sig := s.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := s.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	var encoded bytes.Buffer
+	EncodeDelta(&encoded, delta, sync.Checkpoint{})
+	stream := encoded.Bytes()
+
+	resumed := 0
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		cut := random.Intn(len(stream))
+		out, _ := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+
+		// Link get cut at random offset
+		cp, err := PatchStream(bytes.NewReader(a), bytes.NewReader(stream[:cut]), out, sync.Checkpoint{})
+		if err == nil {
+			t.Fatalf("Expected error with delta stream cut at %d", cut)
+		}
+
+		// Drop unverified output and request remaining stream from checkpoint
+		out.Truncate(cp.Offset)
+		out.Seek(cp.Offset, io.SeekStart)
+
+		var rest bytes.Buffer
+		EncodeDelta(&rest, delta, cp)
+		if _, err := PatchStream(bytes.NewReader(a), &rest, out, cp); err != nil {
+			t.Fatalf("Expected resumed patch from op %d without errors, got %v", cp.Op, err)
+		}
+
+		out.Close()
+		patched, _ := os.ReadFile(out.Name())
+		if !bytes.Equal(patched, b) {
+			t.Fatalf("Expected resumed output equal to target with stream cut at %d", cut)
+		}
+
+		// Remaining stream is shorter than whole stream once a checkpoint get verified
+		if cp.Op > 0 {
+			resumed++
+			if rest.Len() >= len(stream) {
+				t.Errorf("Expected remaining stream smaller than whole stream")
+			}
+		}
+	}
+
+	if resumed == 0 {
+		t.Errorf("Expected some transfers resumed from a verified checkpoint")
+	}
+}
+
+func TestDeltaCheckpointsReadWrite(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test "), 8)
+	b := bytes.Replace(a, []byte("small"), []byte("big"), 2)
+
+	s := sync.New(1<<4, sync.WithCheckpoint(1<<5))
+	sig := s.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := s.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	file := filepath.Join(t.TempDir(), "delta.bin")
+	WriteDelta(file, delta)
+	out, err := ReadDelta(file)
+	if err != nil {
+		t.Fatalf("Expected delta read without errors, got %v", err)
+	}
+
+	if !reflect.DeepEqual(delta, out) {
+		t.Errorf("Expected written delta with checkpoints equal to out delta")
+	}
+}
+
+func TestDeltaLargeOffsets(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "delta.bin")
+	delta := sync.Stream{
+		Checksum: "abc123",
+		Ops: []sync.Op{
+			{Type: sync.OpCopy, Index: 1 << 32, Start: 5 << 30, Offset: 5<<30 + 16},
+			{Type: sync.OpLiteral, Lit: []byte("added")},
+		},
+		Checkpoints: []sync.Checkpoint{{Op: 1, Offset: 1 << 33}},
+	}
+
+	WriteDelta(file, delta)
+	if out, err := ReadDelta(file); err != nil || !reflect.DeepEqual(out, delta) {
+		t.Errorf("Expected offsets beyond 4 GiB read back, got %v", err)
+	}
+
+	// Deltas written with int fields are still readable, gob ints are 64-bit varints
+	type op struct {
+		Type   sync.OpType
+		Index  int
+		Start  int
+		Offset int
+	}
+
+	var encoded bytes.Buffer
+	enc := gob.NewEncoder(&encoded)
+	enc.Encode(header{Checksum: "abc123"})
+	enc.Encode(struct {
+		Kind uint8
+		Op   op
+	}{recordOp, op{Type: sync.OpCopy, Index: 1, Start: 16, Offset: 32}})
+	enc.Encode(record{Kind: recordEnd})
+
+	out, err := decodeDelta(&encoded)
+	if err != nil || len(out.Ops) != 1 || out.Ops[0].Start != 16 || out.Ops[0].Offset != 32 {
+		t.Errorf("Expected delta with int fields decoded, got %+v and %v", out.Ops, err)
+	}
+}
@@ -0,0 +1,328 @@
+package fileio
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Encrypted stream header: magic + random salt used to derive the stream key
+var encryptedMagic = []byte("RSENC01\n")
+
+const saltSize = 16
+
+// Plain bytes sealed in each encrypted chunk, so streams can be
+// decrypted and used chunk by chunk
+const EncryptChunkSize = 1 << 16 // 64 KiB
+
+// Encrypted chunk flags, sealed in nonce so they can't be changed
+const (
+	chunkMore uint8 = iota
+	chunkLast
+)
+
+// Cipher return AEAD for a 32 bytes key, eg. AESGCM or XChaCha20Poly1305
+type Cipher func(key []byte) (cipher.AEAD, error)
+
+// AES-256-GCM cipher
+func AESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// XChaCha20-Poly1305 cipher, faster than AES-GCM without AES hardware support
+func XChaCha20Poly1305(key []byte) (cipher.AEAD, error) {
+	return chacha20poly1305.NewX(key)
+}
+
+// Encryption settings, every stream use its own key derived from Key and a random salt
+type Encryption struct {
+	Key    []byte // Secret key, any size but it must be high entropy
+	Cipher Cipher // AESGCM if nil
+}
+
+// ErrTampered is returned when an encrypted chunk fail authentication,
+// or the stream is truncated or reordered. Decrypted data must be discarded.
+type ErrTampered struct {
+	Chunk uint64
+}
+
+func (e *ErrTampered) Error() string {
+	return fmt.Sprintf("encrypted chunk %d tampered or truncated", e.Chunk)
+}
+
+// Return AEAD for stream key derived from salt
+func (e Encryption) aead(salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, e.Key)
+	mac.Write(encryptedMagic)
+	mac.Write(salt)
+
+	c := e.Cipher
+	if c == nil {
+		c = AESGCM
+	}
+
+	return c(mac.Sum(nil))
+}
+
+// Return nonce for chunk number and flag
+func nonce(size int, chunk uint64, flag uint8) []byte {
+	n := make([]byte, size)
+	binary.BigEndian.PutUint64(n[size-9:], chunk)
+	n[size-1] = flag
+	return n
+}
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header []byte // Authenticated with every chunk
+	buf    []byte
+	chunk  uint64
+}
+
+// Return writer encrypting data to w in chunks of EncryptChunkSize.
+// Close must be called to seal the last chunk, it does not close w.
+func EncryptWriter(w io.Writer, enc Encryption) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := enc.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, encryptedMagic...), salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{w: w, aead: aead, header: header}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		// Keep a full chunk buffered until more data arrive or Close,
+		// so the last chunk is always flagged as such
+		if len(e.buf) == EncryptChunkSize {
+			if err := e.seal(chunkMore); err != nil {
+				return 0, err
+			}
+		}
+
+		n := EncryptChunkSize - len(e.buf)
+		if n > len(p) {
+			n = len(p)
+		}
+
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(chunkLast)
+}
+
+// Write buffered data as encrypted chunk: flag + ciphertext size + ciphertext
+func (e *encryptWriter) seal(flag uint8) error {
+	sealed := e.aead.Seal(nil, nonce(e.aead.NonceSize(), e.chunk, flag), e.buf, e.header)
+	head := make([]byte, 5)
+	head[0] = flag
+	binary.BigEndian.PutUint32(head[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(append(head, sealed...)); err != nil {
+		return err
+	}
+
+	e.buf = e.buf[:0]
+	e.chunk++
+	return nil
+}
+
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	header []byte
+	buf    []byte // Decrypted data not read yet
+	chunk  uint64
+	last   bool
+}
+
+// Return reader decrypting stream written by EncryptWriter.
+// Only authenticated chunks are returned, so data can be used as it get read,
+// reads fail with *ErrTampered on any change, truncation or reordering.
+func DecryptReader(r io.Reader, enc Encryption) (io.Reader, error) {
+	header := make([]byte, len(encryptedMagic)+saltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, &ErrTampered{}
+	}
+
+	if !bytes.Equal(header[:len(encryptedMagic)], encryptedMagic) {
+		return nil, errors.New("data is not encrypted")
+	}
+
+	aead, err := enc.aead(header[len(encryptedMagic):])
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, header: header}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.last {
+			return 0, io.EOF
+		}
+
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// Read and decrypt next chunk
+func (d *decryptReader) open() error {
+	head := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, head); err != nil {
+		return &ErrTampered{Chunk: d.chunk}
+	}
+
+	flag := head[0]
+	size := binary.BigEndian.Uint32(head[1:])
+	if flag > chunkLast || size > EncryptChunkSize+uint32(d.aead.Overhead()) {
+		return &ErrTampered{Chunk: d.chunk}
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return &ErrTampered{Chunk: d.chunk}
+	}
+
+	plain, err := d.aead.Open(sealed[:0], nonce(d.aead.NonceSize(), d.chunk, flag), sealed, d.header)
+	if err != nil {
+		return &ErrTampered{Chunk: d.chunk}
+	}
+
+	// Nothing is expected after last chunk
+	if flag == chunkLast {
+		if n, _ := d.r.Read(make([]byte, 1)); n > 0 {
+			return &ErrTampered{Chunk: d.chunk + 1}
+		}
+	}
+
+	d.buf = plain
+	d.chunk++
+	d.last = flag == chunkLast
+	return nil
+}
+
+// Write encrypted file with contents encoded by encode
+func writeEncrypted(file string, enc Encryption, encode func(io.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	w, err := EncryptWriter(f, enc)
+	if err != nil {
+		return err
+	}
+
+	if err := encode(w); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// Write signature encrypted, remote side only learn its size
+func WriteEncryptedSignature(file string, signatures sync.Signature, enc Encryption) error {
+	if len(signatures.Tables) == 0 {
+		return errors.New("no signatures to write")
+	}
+
+	return writeEncrypted(file, enc, func(w io.Writer) error {
+		return encodeSignature(w, signatures)
+	})
+}
+
+// Read encrypted signature.
+// Return *ErrTampered if file was changed.
+func ReadEncryptedSignature(file string, enc Encryption) (sync.Signature, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	defer f.Close()
+	r, err := DecryptReader(f, enc)
+	if err != nil {
+		return sync.Signature{}, err
+	}
+
+	read, err := decodeSignature(r)
+	if err != nil {
+		return read, err
+	}
+
+	// Read up to last chunk so truncation is detected
+	_, err = io.Copy(io.Discard, r)
+	return read, err
+}
+
+// Write delta stream encrypted, operations and literals included
+func WriteEncryptedDelta(file string, delta sync.Stream, enc Encryption) error {
+	return writeEncrypted(file, enc, func(w io.Writer) error {
+		return EncodeDelta(w, delta, sync.Checkpoint{})
+	})
+}
+
+// Read encrypted delta stream.
+// Return *ErrTampered if file was changed.
+func ReadEncryptedDelta(file string, enc Encryption) (sync.Stream, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return sync.Stream{}, err
+	}
+
+	defer f.Close()
+	r, err := DecryptReader(f, enc)
+	if err != nil {
+		return sync.Stream{}, err
+	}
+
+	read, err := decodeDelta(r)
+	if err != nil {
+		return read, err
+	}
+
+	// Read up to last chunk so truncation is detected
+	_, err = io.Copy(io.Discard, r)
+	return read, err
+}
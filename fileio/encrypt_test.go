@@ -0,0 +1,202 @@
+package fileio
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Return data encrypted with enc
+func encrypt(t *testing.T, data []byte, enc Encryption) []byte {
+	var out bytes.Buffer
+	w, err := EncryptWriter(&out, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write in odd sizes to cross chunk boundaries
+	for len(data) > 0 {
+		n := 1000
+		if n > len(data) {
+			n = len(data)
+		}
+
+		w.Write(data[:n])
+		data = data[n:]
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.Bytes()
+}
+
+// Return decrypted data
+func decrypt(sealed []byte, enc Encryption) ([]byte, error) {
+	r, err := DecryptReader(bytes.NewReader(sealed), enc)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	enc := Encryption{Key: []byte("0123456789abcdef0123456789abcdef")}
+	random := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{0, 10, EncryptChunkSize, 3*EncryptChunkSize + 7} {
+		data := make([]byte, size)
+		random.Read(data)
+
+		sealed := encrypt(t, data, enc)
+		if size > 0 && bytes.Contains(sealed, data[:size/2]) {
+			t.Errorf("Expected no plain data in encrypted stream of %d bytes", size)
+		}
+
+		plain, err := decrypt(sealed, enc)
+		if err != nil || !bytes.Equal(plain, data) {
+			t.Errorf("Expected %d bytes decrypted back, got %d and %v", size, len(plain), err)
+		}
+	}
+}
+
+func TestEncryptTampered(t *testing.T) {
+	enc := Encryption{Key: []byte("0123456789abcdef0123456789abcdef")}
+	data := bytes.Repeat([]byte("i am here guys how are you doing "), EncryptChunkSize/10)
+	sealed := encrypt(t, data, enc)
+
+	header := len(encryptedMagic) + saltSize
+	chunk := 5 + EncryptChunkSize + 16 // flag + size + sealed chunk with GCM tag
+	flip := func(at int) []byte {
+		changed := append([]byte{}, sealed...)
+		changed[at] ^= 1
+		return changed
+	}
+
+	cases := map[string][]byte{
+		"salt":        flip(header - 1),
+		"flag":        flip(header),
+		"first chunk": flip(header + 100),
+		"last chunk":  flip(len(sealed) - 1),
+		"truncated":   sealed[:header+2*chunk],
+		"reordered":   append(append(append([]byte{}, sealed[:header]...), sealed[header+chunk:header+2*chunk]...), sealed[header:header+chunk]...),
+		"trailing":    append(append([]byte{}, sealed...), 0),
+		"wrong key":   encrypt(t, data, Encryption{Key: []byte("other key")}),
+	}
+
+	for name, changed := range cases {
+		var tampered *ErrTampered
+		if _, err := decrypt(changed, enc); !errors.As(err, &tampered) {
+			t.Errorf("Expected %s stream to fail decryption, got %v", name, err)
+		}
+	}
+}
+
+func TestEncryptCipher(t *testing.T) {
+	// AES-128 using half derived key
+	aes128 := func(key []byte) (cipher.AEAD, error) { return AESGCM(key[:16]) }
+	enc := Encryption{Key: []byte("secret"), Cipher: aes128}
+	sealed := encrypt(t, []byte("i am here guys"), enc)
+
+	if plain, err := decrypt(sealed, enc); err != nil || string(plain) != "i am here guys" {
+		t.Errorf("Expected data decrypted with caller cipher, got %q and %v", plain, err)
+	}
+
+	if _, err := decrypt(sealed, Encryption{Key: []byte("secret")}); err == nil {
+		t.Errorf("Expected default cipher to fail with caller cipher stream")
+	}
+}
+
+func TestEncryptXChaCha20Poly1305(t *testing.T) {
+	enc := Encryption{Key: []byte("secret"), Cipher: XChaCha20Poly1305}
+	data := bytes.Repeat([]byte("i am here guys how are you doing "), EncryptChunkSize/10)
+	sealed := encrypt(t, data, enc)
+
+	if plain, err := decrypt(sealed, enc); err != nil || !bytes.Equal(plain, data) {
+		t.Errorf("Expected data decrypted with XChaCha20-Poly1305, got %d bytes and %v", len(plain), err)
+	}
+
+	var tampered *ErrTampered
+	sealed[len(sealed)-1] ^= 1
+	if _, err := decrypt(sealed, enc); !errors.As(err, &tampered) {
+		t.Errorf("Expected tampered XChaCha20-Poly1305 stream to fail decryption, got %v", err)
+	}
+}
+
+func TestEncryptedFiles(t *testing.T) {
+	dir := t.TempDir()
+	enc := Encryption{Key: []byte("0123456789abcdef0123456789abcdef")}
+	delta := mockDelta(t)
+	signatures := sync.Signature{BlockSize: 4, Checksum: "abc123", Tables: []sync.Table{{Weak: 1, Strong: "def456"}}}
+
+	sigFile := filepath.Join(dir, "signature.bin")
+	deltaFile := filepath.Join(dir, "delta.bin")
+	if err := WriteEncryptedSignature(sigFile, signatures, enc); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteEncryptedDelta(deltaFile, delta, enc); err != nil {
+		t.Fatal(err)
+	}
+
+	written, _ := os.ReadFile(sigFile)
+	if bytes.Contains(written, []byte("def456")) {
+		t.Errorf("Expected no block checksums in encrypted signature")
+	}
+
+	sig, err := ReadEncryptedSignature(sigFile, enc)
+	if err != nil || !reflect.DeepEqual(sig, signatures) {
+		t.Errorf("Expected encrypted signature read back, got %v", err)
+	}
+
+	read, err := ReadEncryptedDelta(deltaFile, enc)
+	if err != nil || !reflect.DeepEqual(read, delta) {
+		t.Errorf("Expected encrypted delta read back, got %v", err)
+	}
+
+	// Drop last chunk
+	written, _ = os.ReadFile(deltaFile)
+	os.WriteFile(deltaFile, written[:len(written)-30], 0644)
+	var tampered *ErrTampered
+	if _, err := ReadEncryptedDelta(deltaFile, enc); !errors.As(err, &tampered) {
+		t.Errorf("Expected truncated encrypted delta to fail, got %v", err)
+	}
+}
+
+func TestEncryptedPatchStream(t *testing.T) {
+	enc := Encryption{Key: []byte("0123456789abcdef0123456789abcdef")}
+	var stream bytes.Buffer
+	w, _ := EncryptWriter(&stream, enc)
+	if err := EncodeDelta(w, mockDelta(t), sync.Checkpoint{}); err != nil {
+		t.Fatal(err)
+	}
+
+	w.Close()
+	r, err := DecryptReader(&stream, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src, _ := os.Open("../mock.txt")
+	defer src.Close()
+
+	var out bytes.Buffer
+	if _, err := PatchStream(src, r, &out, sync.Checkpoint{}); err != nil {
+		t.Fatalf("Expected encrypted delta stream to patch, got %v", err)
+	}
+
+	expected, _ := os.ReadFile("../mockV2.txt")
+	if !bytes.Equal(out.Bytes(), expected) {
+		t.Errorf("Expected patched output equal to mockV2.txt")
+	}
+}
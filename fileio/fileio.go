@@ -5,6 +5,8 @@ import (
 	"errors"
 	"math"
 	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
 )
 
 type IO struct {
@@ -12,6 +14,7 @@ type IO struct {
 }
 
 // Factory function
+// Zero block size pick block size for each file using sync.AutoBlockSize
 func New(blockSize int) IO {
 	return IO{
 		blockSize: blockSize,
@@ -26,6 +29,11 @@ func (o IO) Open(input string) (*bufio.Reader, error) {
 		return nil, err
 	}
 
+	return o.OpenFile(file)
+}
+
+// Return buffered reader over open file like Open, file must be kept open while read
+func (o IO) OpenFile(file *os.File) (*bufio.Reader, error) {
 	// Get file info and get total file size
 	fileInfo, _ := file.Stat()
 	fileSize := fileInfo.Size()
@@ -42,5 +50,14 @@ func (o IO) Open(input string) (*bufio.Reader, error) {
 
 // Return chunks length based on file size
 func (o IO) Chunks(fileSize int64) int {
-	return int(math.Ceil(float64(fileSize) / float64(o.blockSize)))
+	return int(math.Ceil(float64(fileSize) / float64(o.BlockSize(fileSize))))
+}
+
+// Return block size used for file size
+func (o IO) BlockSize(fileSize int64) int {
+	if o.blockSize == 0 {
+		return sync.AutoBlockSize(fileSize)
+	}
+
+	return o.blockSize
 }
@@ -40,3 +40,16 @@ func TestFileChunks(t *testing.T) {
 	}
 
 }
+
+func TestAutoBlockSize(t *testing.T) {
+	IO := New(0)
+	reader, err := IO.Open("../mock.txt")
+
+	if err != nil || reader == nil {
+		t.Fatalf("Expected auto block size to split mock.txt in at least two chunks")
+	}
+
+	if IO.BlockSize(1<<20) != 1<<10 {
+		t.Errorf("Expected auto block size 1024 for 1 MiB file")
+	}
+}
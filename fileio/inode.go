@@ -0,0 +1,18 @@
+//go:build !windows && !plan9
+
+package fileio
+
+import (
+	"os"
+	"syscall"
+)
+
+// Return device and inode numbers of file
+func fileID(info os.FileInfo) (uint64, uint64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0
+	}
+
+	return uint64(stat.Dev), uint64(stat.Ino)
+}
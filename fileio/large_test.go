@@ -0,0 +1,121 @@
+package fileio
+
+import (
+	"bufio"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Create sparse file of size with data written at offsets
+func sparseFile(t *testing.T, file string, size int64, data map[int64][]byte) {
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		t.Skipf("Sparse files not supported: %v", err)
+	}
+
+	for offset, chunk := range data {
+		if _, err := f.WriteAt(chunk, offset); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Signature, delta and patch over files beyond 4 GiB, offsets must not
+// overflow on 32-bit builds. Skipped with -short.
+func TestLargeFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("large file test")
+	}
+
+	dir := t.TempDir()
+	size := int64(4<<30 + 3<<20) // 4 GiB + 3 MiB
+	chunk := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(chunk)
+	far := int64(4<<30 + 1<<20)
+
+	basis := filepath.Join(dir, "basis")
+	sparseFile(t, basis, size, map[int64][]byte{0: []byte("basis head"), far: chunk})
+
+	// Same data beyond 4 GiB with a few edited bytes, plus a longer tail
+	edited := append([]byte{}, chunk...)
+	copy(edited[1000:], "edited")
+	target := filepath.Join(dir, "target")
+	sparseFile(t, target, size+100, map[int64][]byte{0: []byte("target head"), far: edited, size: []byte("tail")})
+
+	// Holes are skipped without reading them
+	f, err := os.Open(basis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	basisHoles, err := Holes(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := sync.New(1<<16, sync.WithHoles(basisHoles))
+	if err := WriteSignature(filepath.Join(dir, "signature"), s.BuildSigTable(bufio.NewReader(f))); err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := ReadSignature(filepath.Join(dir, "signature"))
+	if err != nil || len(sig.Tables) != int(size>>16) {
+		t.Fatalf("Expected %d blocks in signature, got %d and %v", size>>16, len(sig.Tables), err)
+	}
+
+	g, _ := os.Open(target)
+	defer g.Close()
+	targetHoles, err := Holes(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = sync.New(0, sync.WithHoles(targetHoles))
+	if err := WriteDelta(filepath.Join(dir, "delta"), s.Diff(sig, bufio.NewReader(g))); err != nil {
+		t.Fatal(err)
+	}
+
+	delta, err := ReadDelta(filepath.Join(dir, "delta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Zeros sent as zero runs, edited block past 4 GiB sent as literal
+	var literal, zero, last int64
+	for _, op := range delta.Ops {
+		switch op.Type {
+		case sync.OpLiteral:
+			literal += op.Len()
+		case sync.OpZero:
+			zero += op.Len()
+		default:
+			if op.Offset > last {
+				last = op.Offset
+			}
+		}
+	}
+
+	if literal > 3<<16 || last != far+1<<20 {
+		t.Errorf("Expected copies up to %d and few literals, got %d and %d literal bytes", far+1<<20, last, literal)
+	}
+
+	if zero < size-3<<20 {
+		t.Errorf("Expected at least %d zero bytes, got %d", size-3<<20, zero)
+	}
+
+	// Patch verify target checksum
+	if err := sync.Patch(f, delta, io.Discard); err != nil {
+		t.Errorf("Expected delta patched over large file, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package fileio
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Tree exchange request kinds
+const (
+	requestHead = iota
+	requestHashes
+	requestTables
+)
+
+// Tree exchange request sent to the side holding the tree
+type treeRequest struct {
+	Kind   int
+	Nodes  []sync.Node
+	Leaves []int
+}
+
+// Tree exchange response
+type treeResponse struct {
+	Head   sync.Head
+	Hashes []string
+	Tables []sync.Table
+	Err    string
+}
+
+// Serve Merkle tree requests from connection until it is closed
+func ServeTree(conn io.ReadWriter, tree *sync.Tree) error {
+	decoder := gob.NewDecoder(conn)
+	encoder := gob.NewEncoder(conn)
+	for {
+		var request treeRequest
+		if err := decoder.Decode(&request); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		var response treeResponse
+		var err error
+		switch request.Kind {
+		case requestHead:
+			response.Head, err = tree.Head()
+		case requestHashes:
+			response.Hashes, err = tree.Hashes(request.Nodes)
+		case requestTables:
+			response.Tables, err = tree.Tables(request.Leaves)
+		default:
+			err = errors.New("unknown tree request")
+		}
+
+		if err != nil {
+			response.Err = err.Error()
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return err
+		}
+	}
+}
+
+// Merkle tree served on the other side of a connection
+type remoteTree struct {
+	encoder *gob.Encoder
+	decoder *gob.Decoder
+}
+
+// Return tree served by ServeTree over connection, see sync.Tree.Fetch
+func RemoteTree(conn io.ReadWriter) sync.Remote {
+	return &remoteTree{encoder: gob.NewEncoder(conn), decoder: gob.NewDecoder(conn)}
+}
+
+// Send request and wait response
+func (r *remoteTree) call(request treeRequest) (treeResponse, error) {
+	var response treeResponse
+	if err := r.encoder.Encode(request); err != nil {
+		return response, err
+	}
+
+	if err := r.decoder.Decode(&response); err != nil {
+		return response, err
+	}
+
+	if response.Err != "" {
+		return response, errors.New(response.Err)
+	}
+
+	return response, nil
+}
+
+func (r *remoteTree) Head() (sync.Head, error) {
+	response, err := r.call(treeRequest{Kind: requestHead})
+	return response.Head, err
+}
+
+func (r *remoteTree) Hashes(nodes []sync.Node) ([]string, error) {
+	response, err := r.call(treeRequest{Kind: requestHashes, Nodes: nodes})
+	if err == nil && len(response.Hashes) != len(nodes) {
+		return nil, errors.New("unexpected hashes count")
+	}
+
+	return response.Hashes, err
+}
+
+func (r *remoteTree) Tables(leaves []int) ([]sync.Table, error) {
+	response, err := r.call(treeRequest{Kind: requestTables, Leaves: leaves})
+	if err == nil && len(response.Tables) != len(leaves) {
+		return nil, errors.New("unexpected tables count")
+	}
+
+	return response.Tables, err
+}
@@ -0,0 +1,48 @@
+package fileio
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+func TestRemoteTree(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<18)
+	random.Read(basis)
+	target := append([]byte{}, basis...)
+	copy(target[100000:], "edited")
+
+	s := sync.New(1 << 10)
+	sig := s.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	client, server := net.Pipe()
+	done := make(chan error)
+	go func() { done <- ServeTree(server, sync.NewTree(sig)) }()
+
+	remote := RemoteTree(client)
+	head, err := remote.Head()
+	if err != nil || head.BlockSize != sig.BlockSize || head.Leaves != len(sig.Tables) {
+		t.Fatalf("Expected remote head, got %+v and %v", head, err)
+	}
+
+	local := sync.NewTree(s.BuildSigTable(bufio.NewReader(bytes.NewReader(target))))
+	fetched, leaves, err := local.Fetch(remote)
+	if err != nil || !reflect.DeepEqual(fetched, sig) || len(leaves) != 1 {
+		t.Fatalf("Expected remote signature fetched with one table, got %v and %v", leaves, err)
+	}
+
+	// Remote errors are returned to caller
+	if _, err := remote.Tables([]int{len(sig.Tables)}); err == nil {
+		t.Errorf("Expected remote error for block out of signature")
+	}
+
+	client.Close()
+	if err := <-done; err != nil {
+		t.Errorf("Expected serve end on close, got %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package fileio
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNotMapped is returned when file can't be memory mapped, eg. pipes, empty files,
+// files larger than address space or platforms without mmap. It should be streamed
+// instead, see Open.
+var ErrNotMapped = errors.New("file can't be memory mapped")
+
+// Mapped expose memory mapped file content as a byte slice,
+// so signature and delta could roll over it without copies, see sync.DiffBytes.
+type Mapped struct {
+	data []byte
+}
+
+// Map file into memory and ensure split to at least two chunks, see Open
+func (o IO) Map(input string) (*Mapped, error) {
+	file, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+	return o.MapFile(file)
+}
+
+// Map open file into memory like Map, mapping survive file close
+func (o IO) MapFile(file *os.File) (*Mapped, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := mapFile(file, info)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Chunks(int64(m.Len())) <= 1 {
+		m.Close()
+		return nil, errors.New("at least 2 chunks are required")
+	}
+
+	return m, nil
+}
+
+// Return mapped file or ErrNotMapped
+func mapFile(file *os.File, info os.FileInfo) (*Mapped, error) {
+	size := info.Size()
+	if !info.Mode().IsRegular() || size == 0 || int64(int(size)) != size {
+		return nil, ErrNotMapped
+	}
+
+	// Mapping survive file close
+	data, err := mmap(file, int(size))
+	if err != nil {
+		return nil, ErrNotMapped
+	}
+
+	return &Mapped{data: data}, nil
+}
+
+// Return file content, valid until Close
+func (m *Mapped) Bytes() []byte { return m.data }
+
+// Return file size
+func (m *Mapped) Len() int { return len(m.data) }
+
+// Read file content at offset
+func (m *Mapped) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Release file content, slices returned by Bytes must not be used after
+func (m *Mapped) Close() error {
+	if m.data == nil {
+		return nil
+	}
+
+	data := m.data
+	m.data = nil
+	return munmap(data)
+}
@@ -0,0 +1,18 @@
+//go:build linux
+
+package fileio
+
+import (
+	"os"
+	"syscall"
+)
+
+// Map file read only into memory
+func mmap(f *os.File, size int) ([]byte, error) {
+	return syscall.Mmap(int(f.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// Release mapped memory
+func munmap(data []byte) error {
+	return syscall.Munmap(data)
+}
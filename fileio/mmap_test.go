@@ -0,0 +1,135 @@
+package fileio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+func TestMap(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "basis.txt")
+	data := []byte("i am here guys how are you doing")
+	os.WriteFile(file, data, 0644)
+
+	m, err := New(8).Map(file)
+	if runtime.GOOS != "linux" && errors.Is(err, ErrNotMapped) {
+		t.Skip("Memory mapping not supported")
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(m.Bytes(), data) {
+		t.Errorf("Expected file content mapped")
+	}
+
+	p := make([]byte, 8)
+	if n, err := m.ReadAt(p, 28); n != 4 || err != io.EOF || string(p[:n]) != "oing" {
+		t.Errorf("Expected short read at end of file, got %d and %v", n, err)
+	}
+
+	if err := m.Close(); err != nil || m.Bytes() != nil {
+		t.Errorf("Expected content released on close, got %v", err)
+	}
+
+	if _, err := New(64).Map(file); err == nil {
+		t.Errorf("Expected error for file smaller than two chunks")
+	}
+}
+
+func TestMapNotMapped(t *testing.T) {
+	r, w, _ := os.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	// Pipes are streamed, never read into memory
+	info, _ := r.Stat()
+	if _, err := mapFile(r, info); !errors.Is(err, ErrNotMapped) {
+		t.Errorf("Expected pipe not mapped, got %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	os.WriteFile(empty, nil, 0644)
+	if _, err := New(8).Map(empty); !errors.Is(err, ErrNotMapped) {
+		t.Errorf("Expected empty file not mapped, got %v", err)
+	}
+}
+
+// Write random file with a few edited copies
+func largeFiles(b *testing.B, size int) (string, string) {
+	dir := b.TempDir()
+	data := make([]byte, size)
+	rand.New(rand.NewSource(1)).Read(data)
+	basis := filepath.Join(dir, "basis")
+	os.WriteFile(basis, data, 0644)
+
+	for at := 0; at < size; at += size / 16 {
+		data[at] ^= 0xff
+	}
+
+	target := filepath.Join(dir, "target")
+	os.WriteFile(target, data, 0644)
+	return basis, target
+}
+
+// Compare buffered reader and memory mapped input over large files.
+// Skipped with -short, see Makefile benchmark task.
+func BenchmarkLargeFile(b *testing.B) {
+	if testing.Short() {
+		b.Skip("large file benchmark")
+	}
+
+	size := 1 << 25 // 32 MiB
+	basis, target := largeFiles(b, size)
+	s := sync.New(1 << 12)
+	io := New(1 << 12)
+
+	b.Run("signature/bufio", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		for i := 0; i < b.N; i++ {
+			f, _ := os.Open(basis)
+			s.BuildSigTable(bufio.NewReader(f))
+			f.Close()
+		}
+	})
+
+	b.Run("signature/mmap", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		for i := 0; i < b.N; i++ {
+			m, _ := io.Map(basis)
+			s.BuildSigTableBytes(m.Bytes())
+			m.Close()
+		}
+	})
+
+	f, _ := os.Open(basis)
+	sig := s.BuildSigTable(bufio.NewReader(f))
+	f.Close()
+
+	b.Run("delta/bufio", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		for i := 0; i < b.N; i++ {
+			f, _ := os.Open(target)
+			s.Diff(sig, bufio.NewReader(f))
+			f.Close()
+		}
+	})
+
+	b.Run("delta/mmap", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		for i := 0; i < b.N; i++ {
+			m, _ := io.Map(target)
+			s.DiffBytes(sig, m.Bytes())
+			m.Close()
+		}
+	})
+}
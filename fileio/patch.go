@@ -0,0 +1,98 @@
+package fileio
+
+import (
+	"io"
+	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Apply delta over source file and write the rebuilt target into output file.
+// Source file is never modified, and output is removed if patching
+// or checksum verification fail, so no corrupted target is left behind.
+func Patch(source string, output string, delta sync.Stream) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+
+	err = patch(src, delta, out)
+	// Keep first error found, patching error has priority
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(output)
+		return err
+	}
+
+	return nil
+}
+
+// Apply delta over file replacing it atomically.
+// Target is rebuilt in a temporary file in the same directory, synced to disk
+// and renamed over file only after checksum verification, so a crash or
+// failure at any point leave the original or the new version intact.
+func Apply(file string, delta sync.Stream) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+	out, err := CreateAtomic(file)
+	if err != nil {
+		return err
+	}
+
+	if err := patch(src, delta, out.File); err != nil {
+		out.Abort()
+		return err
+	}
+
+	return out.Commit()
+}
+
+// Apply delta over file rewriting it directly, as rsync --inplace does.
+// Only deltas where copy operations move data backward can be applied in place,
+// otherwise it fall back to atomic Apply. No extra disk space is needed but
+// file is left corrupted if patching get interrupted or verification fail.
+func ApplyInPlace(file string, delta sync.Stream) error {
+	if !sync.InPlace(delta) {
+		return Apply(file, delta)
+	}
+
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	if err := patch(f, delta, f); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// Apply delta over source writing into out from its start, zero runs are
+// left as holes. Out is truncated to target size, removing remaining old data.
+func patch(source io.ReaderAt, delta sync.Stream, out *os.File) error {
+	writer, err := newOffsetWriter(out)
+	if err != nil {
+		return err
+	}
+
+	if err := sync.Patch(source, delta, writer); err != nil {
+		return err
+	}
+
+	return out.Truncate(writer.offset)
+}
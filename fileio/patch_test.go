@@ -0,0 +1,148 @@
+package fileio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Build delta between mock files using same block size as integration
+func mockDelta(t *testing.T) sync.Stream {
+	io := New(1 << 4)
+	s := sync.New(1 << 4)
+
+	v1, err := io.Open("../mock.txt")
+	if err != nil {
+		t.Fatal("Expected to be able to read the original file")
+	}
+
+	v2, err := io.Open("../mockV2.txt")
+	if err != nil {
+		t.Fatal("Expected to be able to read the V2 file")
+	}
+
+	return s.Diff(s.BuildSigTable(v1), v2)
+}
+
+func TestPatchFile(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "patched.txt")
+	delta := mockDelta(t)
+
+	if err := Patch("../mock.txt", output, delta); err != nil {
+		t.Fatalf("Expected patch without errors, got %v", err)
+	}
+
+	expected, _ := os.ReadFile("../mockV2.txt")
+	patched, _ := os.ReadFile(output)
+	if !bytes.Equal(expected, patched) {
+		t.Errorf("Expected patched file equal to mockV2.txt")
+	}
+}
+
+func TestPatchFileChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	output := filepath.Join(dir, "patched.txt")
+	delta := mockDelta(t)
+
+	// Corrupt source file keeping the same size
+	original, _ := os.ReadFile("../mock.txt")
+	corrupted := bytes.ToUpper(original)
+	os.WriteFile(source, corrupted, 0644)
+
+	err := Patch(source, output, delta)
+	var mismatch *sync.ErrChecksumMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch patching corrupted source, got %v", err)
+	}
+
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("Expected output removed after failed verification")
+	}
+
+	intact, _ := os.ReadFile(source)
+	if !bytes.Equal(intact, corrupted) {
+		t.Errorf("Expected source file intact after failed verification")
+	}
+}
+
+func TestApply(t *testing.T) {
+	delta := mockDelta(t)
+	original, _ := os.ReadFile("../mock.txt")
+	expected, _ := os.ReadFile("../mockV2.txt")
+
+	for _, apply := range []func(string, sync.Stream) error{Apply, ApplyInPlace} {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "mock.txt")
+		os.WriteFile(file, original, 0644)
+
+		if err := apply(file, delta); err != nil {
+			t.Fatalf("Expected apply without errors, got %v", err)
+		}
+
+		patched, _ := os.ReadFile(file)
+		if !bytes.Equal(expected, patched) {
+			t.Errorf("Expected file patched to mockV2.txt")
+		}
+
+		checkClean(t, dir)
+	}
+}
+
+func TestApplyInPlaceShrink(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.txt")
+	original := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	os.WriteFile(file, original, 0644)
+
+	// Drop first block, remaining ones move backward
+	delta := sync.Stream{
+		Checksum: sync.New(1 << 4).BuildSigTable(bufio.NewReader(bytes.NewReader(original[16:]))).Checksum,
+		Ops: []sync.Op{
+			{Type: sync.OpCopy, Index: 1, Start: 16, Offset: int64(len(original))},
+		},
+	}
+
+	if !sync.InPlace(delta) {
+		t.Fatal("Expected delta to be applied in place")
+	}
+
+	if err := ApplyInPlace(file, delta); err != nil {
+		t.Fatalf("Expected apply in place without errors, got %v", err)
+	}
+
+	patched, _ := os.ReadFile(file)
+	if !bytes.Equal(original[16:], patched) {
+		t.Errorf("Expected file truncated to new version, got %q", patched)
+	}
+}
+
+func TestApplyInterrupted(t *testing.T) {
+	delta := mockDelta(t)
+	original, _ := os.ReadFile("../mock.txt")
+
+	// Delta stream cut at any operation never replace original
+	for cut := 0; cut < len(delta.Ops); cut++ {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "mock.txt")
+		os.WriteFile(file, original, 0644)
+
+		partial := sync.Stream{Checksum: delta.Checksum, Ops: delta.Ops[:cut]}
+		err := Apply(file, partial)
+		var mismatch *sync.ErrChecksumMismatch
+		if !errors.As(err, &mismatch) {
+			t.Fatalf("Expected ErrChecksumMismatch applying delta cut at %d, got %v", cut, err)
+		}
+
+		content, _ := os.ReadFile(file)
+		if !bytes.Equal(content, original) {
+			t.Fatalf("Expected original intact applying delta cut at %d", cut)
+		}
+
+		checkClean(t, dir)
+	}
+}
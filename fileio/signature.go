@@ -3,6 +3,7 @@ package fileio
 import (
 	"encoding/gob"
 	"errors"
+	"io"
 	"os"
 
 	"github.com/geolffreym/rolling-sync/sync"
@@ -10,9 +11,9 @@ import (
 
 // Write signature based on signature table
 // Return error if file creation fail or encode signatures fail
-func WriteSignature(file string, signatures []sync.Table) error {
+func WriteSignature(file string, signatures sync.Signature) error {
 
-	if len(signatures) == 0 {
+	if len(signatures.Tables) == 0 {
 		return errors.New("no signatures to write")
 	}
 
@@ -23,31 +24,34 @@ func WriteSignature(file string, signatures []sync.Table) error {
 	}
 
 	defer f.Close()
-	enc := gob.NewEncoder(f)
-	err = enc.Encode(signatures)
-	if err != nil {
-		return err
-	}
+	return encodeSignature(f, signatures)
+}
 
-	return nil
+// Encode signatures into writer
+func encodeSignature(w io.Writer, signatures sync.Signature) error {
+	return gob.NewEncoder(w).Encode(signatures)
 }
 
 // Read signatures from file and decode it
 // Return error if file reading fail or decode signatures fail
-func ReadSignature(file string) ([]sync.Table, error) {
+func ReadSignature(file string) (sync.Signature, error) {
 	f, err := os.Open(file)
 	if err != nil {
-		return nil, err
+		return sync.Signature{}, err
 	}
 
 	defer f.Close()
-	var read []sync.Table
+	return decodeSignature(f)
+}
 
-	dataDecoder := gob.NewDecoder(f)
-	err = dataDecoder.Decode(&read)
+// Decode signatures from reader
+func decodeSignature(r io.Reader) (sync.Signature, error) {
+	var read sync.Signature
+	dataDecoder := gob.NewDecoder(r)
+	err := dataDecoder.Decode(&read)
 
 	if err != nil {
-		return nil, err
+		return read, err
 	}
 
 	return read, nil
@@ -11,7 +11,7 @@ import (
 func TestSignatureReadWrite(t *testing.T) {
 	// Read file to split in chunks
 	signature := sync.Table{Weak: 0000, Strong: "abc123"}
-	signatures := []sync.Table{signature}
+	signatures := sync.Signature{Checksum: "abc123", Tables: []sync.Table{signature}}
 	WriteSignature("signature.bin", signatures)
 	out, _ := ReadSignature("signature.bin")
 
@@ -22,7 +22,7 @@ func TestSignatureReadWrite(t *testing.T) {
 }
 
 func TestSignatureBadWrite(t *testing.T) {
-	signatures := []sync.Table{}
+	signatures := sync.Signature{}
 	err := WriteSignature("signature.bin", signatures)
 
 	if err == nil {
@@ -31,7 +31,7 @@ func TestSignatureBadWrite(t *testing.T) {
 }
 
 func TestSignatureBadFileWrite(t *testing.T) {
-	signatures := []sync.Table{}
+	signatures := sync.Signature{}
 	err := WriteSignature("notexists.bin", signatures)
 
 	if err == nil {
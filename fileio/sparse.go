@@ -0,0 +1,90 @@
+package fileio
+
+import (
+	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Return holes in open file, ranges known to read as zeros without being stored.
+// No holes are returned if file system can't report them. File is left at start,
+// it should be read through the same handle so holes match the data read.
+func Holes(f *os.File) ([]sync.Range, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Pipes and devices can't seek to data
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	return holes(f, info.Size())
+}
+
+// Sequential writer over file, zero runs are left as holes.
+// Output written beyond file size is not stored, holes inside it are punched
+// and file must be truncated to offset when done.
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+	size   int64 // File size before writing
+}
+
+// Factory function
+func newOffsetWriter(f *os.File) (*offsetWriter, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &offsetWriter{f: f, size: info.Size()}, nil
+}
+
+func (o *offsetWriter) Write(p []byte) (int, error) {
+	n, err := o.f.WriteAt(p, o.offset)
+	o.offset += int64(n)
+	return n, err
+}
+
+// Skip zero run, punching old data it overwrite
+func (o *offsetWriter) Hole(size int64) error {
+	end := o.offset + size
+	if o.offset < o.size {
+		stored := end
+		if stored > o.size {
+			stored = o.size
+		}
+
+		// Write zeros if file system can't punch holes
+		if err := punchHole(o.f, o.offset, stored-o.offset); err != nil {
+			if err := writeZeros(o.f, o.offset, stored-o.offset); err != nil {
+				return err
+			}
+		}
+	}
+
+	o.offset = end
+	return nil
+}
+
+// Write size zero bytes into file at offset
+func writeZeros(f *os.File, offset, size int64) error {
+	chunk := make([]byte, 1<<16)
+	for size > 0 {
+		if size < int64(len(chunk)) {
+			chunk = chunk[:size]
+		}
+
+		written, err := f.WriteAt(chunk, offset)
+		if err != nil {
+			return err
+		}
+
+		offset += int64(written)
+		size -= int64(written)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package fileio
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Seek whence values to find data and holes, see lseek(2)
+const (
+	seekData = 3
+	seekHole = 4
+)
+
+// Fallocate mode deallocating a range, see fallocate(2)
+const punchMode = 0x1 | 0x2 // FALLOC_FL_KEEP_SIZE | FALLOC_FL_PUNCH_HOLE
+
+// Return holes in file of size walking data and hole regions,
+// file is left at start to be read afterwards
+func holes(f *os.File, size int64) ([]sync.Range, error) {
+	defer f.Seek(0, io.SeekStart)
+	var found []sync.Range
+	var offset int64
+	for offset < size {
+		data, err := f.Seek(offset, seekData)
+		// No data after offset, file end with a hole
+		if errors.Is(err, syscall.ENXIO) {
+			data = size
+		} else if errors.Is(err, syscall.EINVAL) {
+			return nil, nil // Not supported
+		} else if err != nil {
+			return nil, err
+		}
+
+		if data > offset {
+			found = append(found, sync.Range{Start: offset, Offset: data})
+		}
+
+		if data >= size {
+			break
+		}
+
+		if offset, err = f.Seek(data, seekHole); err != nil {
+			return nil, err
+		}
+	}
+
+	return found, nil
+}
+
+// Deallocate file range keeping file size, range read as zeros after
+func punchHole(f *os.File, offset, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+
+	return syscall.Fallocate(int(f.Fd()), punchMode, offset, size)
+}
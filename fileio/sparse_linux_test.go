@@ -0,0 +1,151 @@
+//go:build linux
+
+package fileio
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Return bytes allocated on disk for file
+func allocated(t *testing.T, file string) int64 {
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return info.Sys().(*syscall.Stat_t).Blocks * 512
+}
+
+// Return file holes, skip test if file system don't report them
+func fileHoles(t *testing.T, file string) []sync.Range {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	holes, err := Holes(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(holes) == 0 {
+		t.Skip("Holes not supported by file system")
+	}
+
+	return holes
+}
+
+func TestHoles(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "sparse")
+	sparseFile(t, file, 4<<20, map[int64][]byte{0: []byte("head"), 2 << 20: []byte("middle")})
+	holes := fileHoles(t, file)
+
+	data, _ := os.ReadFile(file)
+	var covered int64
+	for _, hole := range holes {
+		if hole.Start >= hole.Offset || hole.Offset > 4<<20 {
+			t.Fatalf("Expected hole inside file, got %v", hole)
+		}
+
+		if bytes.Count(data[hole.Start:hole.Offset], []byte{0}) != int(hole.Offset-hole.Start) {
+			t.Errorf("Expected hole %v to read as zeros", hole)
+		}
+
+		covered += hole.Offset - hole.Start
+	}
+
+	// Data is allocated in file system blocks, at most a few MiB around it
+	if covered < 3<<20 || holes[len(holes)-1].Offset != 4<<20 {
+		t.Errorf("Expected holes covering file but data, got %v", holes)
+	}
+}
+
+// Basis is fully allocated, target has same head and tail with a large hole between
+func sparseDelta(t *testing.T, dir string) (string, string, sync.Stream) {
+	data := make([]byte, 8<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	basis := filepath.Join(dir, "basis")
+	os.WriteFile(basis, data, 0644)
+
+	target := filepath.Join(dir, "target")
+	sparseFile(t, target, 8<<20, map[int64][]byte{0: data[:1<<20], 7 << 20: data[7<<20:]})
+	holes := fileHoles(t, target)
+
+	s := sync.New(1 << 12)
+	f, _ := os.Open(basis)
+	defer f.Close()
+	sig := s.BuildSigTable(bufio.NewReader(f))
+
+	g, _ := os.Open(target)
+	defer g.Close()
+	return basis, target, sync.New(0, sync.WithHoles(holes)).Diff(sig, bufio.NewReader(g))
+}
+
+func TestPatchSparse(t *testing.T) {
+	dir := t.TempDir()
+	basis, target, delta := sparseDelta(t, dir)
+	if stats := sync.NewStats(sync.Signature{}, delta); stats.ZeroBytes != 6<<20 || stats.LiteralBytes != 0 {
+		t.Fatalf("Expected hole sent as zero run, got %d zero and %d literal bytes", stats.ZeroBytes, stats.LiteralBytes)
+	}
+
+	output := filepath.Join(dir, "patched")
+	if err := Patch(basis, output, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := os.ReadFile(target)
+	patched, _ := os.ReadFile(output)
+	if !bytes.Equal(expected, patched) {
+		t.Fatalf("Expected patched file equal to target")
+	}
+
+	if size := allocated(t, output); size > 3<<20 {
+		t.Errorf("Expected hole left in patched file, got %d bytes allocated", size)
+	}
+}
+
+func TestApplyInPlaceSparse(t *testing.T) {
+	dir := t.TempDir()
+	basis, target, delta := sparseDelta(t, dir)
+	if !sync.InPlace(delta) {
+		t.Fatal("Expected delta applicable in place")
+	}
+
+	if err := ApplyInPlace(basis, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := os.ReadFile(target)
+	patched, _ := os.ReadFile(basis)
+	if !bytes.Equal(expected, patched) {
+		t.Fatalf("Expected patched file equal to target")
+	}
+
+	// Old data is punched out where target has a hole
+	if size := allocated(t, basis); size > 3<<20 {
+		t.Errorf("Expected hole punched in patched file, got %d bytes allocated", size)
+	}
+}
+
+func TestApplySparse(t *testing.T) {
+	dir := t.TempDir()
+	basis, target, delta := sparseDelta(t, dir)
+	if err := Apply(basis, delta); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := os.ReadFile(target)
+	patched, _ := os.ReadFile(basis)
+	if !bytes.Equal(expected, patched) || allocated(t, basis) > 3<<20 {
+		t.Errorf("Expected sparse patched file equal to target, got %d bytes allocated", allocated(t, basis))
+	}
+}
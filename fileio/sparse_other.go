@@ -0,0 +1,19 @@
+//go:build !linux
+
+package fileio
+
+import (
+	"errors"
+	"os"
+
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Holes can't be detected, file is read as dense
+func holes(f *os.File, size int64) ([]sync.Range, error) {
+	return nil, nil
+}
+
+func punchHole(f *os.File, offset, size int64) error {
+	return errors.New("punching holes not supported")
+}
@@ -2,6 +2,11 @@ module github.com/geolffreym/rolling-sync
 
 go 1.18
 
+require (
+	github.com/klauspost/compress v1.15.15
+	golang.org/x/crypto v0.0.0-20220525230936-793ad666bf5e
+)
+
 require (
 	github.com/chzyer/readline v1.5.0 // indirect
 	github.com/google/pprof v0.0.0-20220412212628-83db2b799d1f // indirect
@@ -7,32 +7,454 @@ https://en.wikipedia.org/wiki/Adler-32
 https://www.zlib.net/maxino06_fletcher-adler.pdf
 https://www.sciencedirect.com/science/article/pii/S1742287606000764#fig2
 https://xilinx.github.io/Vitis_Libraries/security/2020.2/guide_L1/internals/adler32.html
-**/
+*
+*/
 package main
 
 import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
 	IO "github.com/geolffreym/rolling-sync/fileio"
+	Repo "github.com/geolffreym/rolling-sync/repo"
 	Sync "github.com/geolffreym/rolling-sync/sync"
 )
 
+const usage = `usage:
+  rolling-sync signature [-block size] [-seed] [-cache dir] [-key file|-secret file] <basis> <signature>
+  rolling-sync delta [-basis file [-edits|-bsdiff]] [-key file|-secret file] <signature> <newfile> <delta>
+  rolling-sync stat [-basis file [-edits|-bsdiff]] [-key file|-secret file] <signature> <newfile>
+  rolling-sync patch [--inplace] [-key file|-secret file] <basis> <delta> [output]
+  rolling-sync commit [-rebase n] <repository> <file>
+  rolling-sync checkout <repository> <version> <output>
+  rolling-sync log <repository>
+  rolling-sync prune [-last n] [-daily n] [-weekly n] <repository>
+  rolling-sync scrub <repository>`
+
 func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// Dispatch command line sub commands, rdiff alike
+func run(args []string) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	blockSize := cmd.Int("block", 0, "block size in bytes, 0 pick it from file size")
+	seed := cmd.Bool("seed", false, "key block checksums with a random seed stored in signature")
+	cacheDir := cmd.String("cache", "", "directory to cache signatures of unchanged files")
+	keyFile := cmd.String("key", "", "HMAC-SHA256 key file to sign and verify signature and delta files")
+	secretFile := cmd.String("secret", "", "key file to encrypt signature and delta files")
+	inplace := cmd.Bool("inplace", false, "rewrite basis file directly when possible")
+	basisFile := cmd.String("basis", "", "basis file to extend matches byte by byte")
+	edits := cmd.Bool("edits", false, "store edit scripts against basis when smaller than literals")
+	bsdiff := cmd.Bool("bsdiff", false, "use bsdiff algorithm against basis, better for executables")
+	rebase := cmd.Int("rebase", Repo.RebaseInterval, "max deltas between full versions in repository")
+	last := cmd.Int("last", 0, "keep last n versions")
+	daily := cmd.Int("daily", 0, "keep latest version of last n days")
+	weekly := cmd.Int("weekly", 0, "keep latest version of last n weeks")
+	if err := cmd.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	io := IO.New(*blockSize)
+	params := cmd.Args()
+	var files format
+	if *keyFile != "" && *secretFile != "" {
+		return errors.New("encrypted files are authenticated, use either -key or -secret")
+	}
+
+	if *keyFile != "" {
+		key, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+
+		files.auth = IO.NewHMAC(key)
+	}
+
+	if *secretFile != "" {
+		secret, err := os.ReadFile(*secretFile)
+		if err != nil {
+			return err
+		}
+
+		files.enc = &IO.Encryption{Key: secret}
+	}
+
+	switch {
+	case args[0] == "signature" && len(params) == 2:
+		// Unchanged basis signature is taken from cache
+		if *cacheDir != "" {
+			cache, err := IO.OpenCache(*cacheDir)
+			if err != nil {
+				return err
+			}
+
+			// Seeded signatures are built with a fresh seed, never cached
+			var key []byte
+			if *seed {
+				if key, err = Sync.NewSeed(); err != nil {
+					return err
+				}
+			}
+
+			sig, err := cache.Signature(params[0], *blockSize, key)
+			if err != nil {
+				return err
+			}
+
+			return files.writeSignature(params[1], sig)
+		}
+
+		info, err := os.Stat(params[0])
+		if err != nil {
+			return err
+		}
+
+		basis, err := openInput(io, params[0])
+		if err != nil {
+			return err
+		}
+
+		defer basis.Close()
+		options := []Sync.Option{Sync.WithFileSize(info.Size()), Sync.WithHoles(basis.holes)}
+		if *seed {
+			key, err := Sync.NewSeed()
+			if err != nil {
+				return err
+			}
+
+			options = append(options, Sync.WithSeed(key))
+		}
+
+		sync := Sync.New(*blockSize, options...)
+
+		sig := basis.signature(sync) // Signature file for "source"
+		return files.writeSignature(params[1], sig)
+
+	case args[0] == "delta" && len(params) == 3:
+		sig, err := files.readSignature(params[0])
+		if err != nil {
+			return err
+		}
+
+		target, err := openInput(io, params[1])
+		if err != nil {
+			return err
+		}
+
+		defer target.Close()
+		options, closeBasis, err := deltaOptions(*basisFile, *edits, *bsdiff)
+		if err != nil {
+			return err
+		}
+
+		defer closeBasis()
+		// Block size is taken from signature
+		options = append(options, Sync.WithHoles(target.holes))
+		delta := target.diff(Sync.New(0, options...), sig) // Return delta with "sig" and "target" differences
+		return files.writeDelta(params[2], delta)
+
+	case args[0] == "stat" && len(params) == 2:
+		sig, err := files.readSignature(params[0])
+		if err != nil {
+			return err
+		}
+
+		target, err := io.Open(params[1])
+		if err != nil {
+			return err
+		}
+
+		options, closeBasis, err := deltaOptions(*basisFile, *edits, *bsdiff)
+		if err != nil {
+			return err
+		}
+
+		defer closeBasis()
+		_, stats := Sync.New(0, options...).DiffStats(sig, target)
+		fmt.Println(stats)
+		return nil
+
+	case args[0] == "patch" && (len(params) == 2 || len(params) == 3):
+		delta, err := files.readDelta(params[1])
+		if err != nil {
+			return err
+		}
+
+		// Write new version apart keeping basis untouched
+		if len(params) == 3 {
+			return IO.Patch(params[0], params[2], delta)
+		}
+
+		if *inplace {
+			return IO.ApplyInPlace(params[0], delta)
+		}
+
+		return IO.Apply(params[0], delta)
+
+	case args[0] == "commit" && len(params) == 2:
+		repo, err := Repo.Open(params[0], Repo.WithRebase(*rebase))
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(params[1])
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		version, err := repo.Commit(f)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(logLine(version))
+		return nil
+
+	case args[0] == "checkout" && len(params) == 3:
+		repo, err := Repo.Open(params[0])
+		if err != nil {
+			return err
+		}
+
+		number, err := strconv.Atoi(params[1])
+		if err != nil {
+			return err
+		}
+
+		out, err := IO.CreateAtomic(params[2])
+		if err != nil {
+			return err
+		}
+
+		if err := repo.Checkout(number, out); err != nil {
+			out.Abort()
+			return err
+		}
+
+		return out.Commit()
+
+	case args[0] == "log" && len(params) == 1:
+		repo, err := Repo.Open(params[0])
+		if err != nil {
+			return err
+		}
+
+		for _, version := range repo.Log() {
+			fmt.Println(logLine(version))
+		}
+
+		return nil
+
+	case args[0] == "prune" && len(params) == 1:
+		// Empty policy would remove every version but the latest
+		if *last <= 0 && *daily <= 0 && *weekly <= 0 {
+			return errors.New("prune require at least one of -last, -daily or -weekly")
+		}
+
+		repo, err := Repo.Open(params[0])
+		if err != nil {
+			return err
+		}
+
+		removed, err := repo.Prune(Repo.Policy{Last: *last, Daily: *daily, Weekly: *weekly})
+		for _, version := range removed {
+			fmt.Println("removed", logLine(version))
+		}
+
+		return err
+
+	case args[0] == "scrub" && len(params) == 1:
+		repo, err := Repo.Open(params[0])
+		if err != nil {
+			return err
+		}
+
+		report, err := repo.Scrub()
+		if err != nil {
+			return err
+		}
 
-	// Example usage
-	blockSize := 1 << 4 // 16 bytes
-	io := IO.New(blockSize)
-	sync := Sync.New(blockSize)
+		for _, id := range report.Corrupted {
+			fmt.Println("corrupted chunk", id)
+		}
 
-	v1, err := io.Open("mock.txt")
+		for _, id := range report.Missing {
+			fmt.Println("missing chunk", id)
+		}
+
+		fmt.Println(report.Chunks, "chunks checked")
+		if !report.OK() {
+			return errors.New("repository store is corrupted")
+		}
+
+		return nil
+	}
+
+	return errors.New(usage)
+}
+
+// Input file memory mapped, or buffered if it can't be mapped,
+// with holes detected on the same open file
+type input struct {
+	file   *os.File
+	mapped *IO.Mapped
+	reader *bufio.Reader
+	holes  []Sync.Range
+}
+
+func openInput(io IO.IO, file string) (input, error) {
+	f, err := os.Open(file)
 	if err != nil {
-		panic("Fail opening mock.txt")
+		return input{}, err
+	}
+
+	in := input{file: f}
+	if in.holes, err = IO.Holes(f); err != nil {
+		f.Close()
+		return input{}, err
+	}
+
+	in.mapped, err = io.MapFile(f)
+	if errors.Is(err, IO.ErrNotMapped) {
+		in.reader, err = io.OpenFile(f)
 	}
 
-	v2, err := io.Open("mockV2.txt")
 	if err != nil {
-		panic("Fail opening mockV2.txt")
+		f.Close()
+		return input{}, err
+	}
+
+	return in, nil
+}
+
+func (i input) signature(sync *Sync.Sync) Sync.Signature {
+	if i.mapped == nil {
+		return sync.BuildSigTable(i.reader)
+	}
+
+	return sync.BuildSigTableBytes(i.mapped.Bytes())
+}
+
+func (i input) diff(sync *Sync.Sync, sig Sync.Signature) Sync.Stream {
+	if i.mapped == nil {
+		return sync.Diff(sig, i.reader)
+	}
+
+	return sync.DiffBytes(sig, i.mapped.Bytes())
+}
+
+func (i input) Close() {
+	if i.mapped != nil {
+		i.mapped.Close()
+	}
+
+	i.file.Close()
+}
+
+// Return delta options for command flags, plus a func to close basis file
+func deltaOptions(basisFile string, edits, bsdiff bool) ([]Sync.Option, func(), error) {
+	var options []Sync.Option
+	closeBasis := func() {}
+	if basisFile == "" && (edits || bsdiff) {
+		return nil, nil, errors.New("-edits and -bsdiff require -basis")
 	}
 
-	sig := sync.BuildSigTable(v1) // Signature file for "source"
-	sync.Delta(sig, v2)           // Return delta with "sig" and "target" differences
+	if basisFile != "" {
+		f, err := os.Open(basisFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		closeBasis = func() { f.Close() }
+		options = append(options, Sync.WithBasis(f))
+	}
+
+	if edits {
+		options = append(options, Sync.WithEditScripts())
+	}
+
+	if bsdiff {
+		options = append(options, Sync.WithBSDiff())
+	}
+
+	return options, closeBasis, nil
+}
+
+// Return version description for log
+func logLine(version Repo.Version) string {
+	kind := "delta"
+	if version.Base {
+		kind = "base"
+	}
+
+	return fmt.Sprintf("%d\t%s\t%s\t%d bytes\t%d stored",
+		version.Number, version.Time.Format(time.RFC3339), kind, version.Size, version.Stored)
+}
+
+// Signature and delta files format, signed or encrypted
+type format struct {
+	auth IO.Authenticator
+	enc  *IO.Encryption
+}
+
+// Write signature, signed or encrypted if set
+func (f format) writeSignature(file string, sig Sync.Signature) error {
+	switch {
+	case f.auth != nil:
+		return IO.WriteSignedSignature(file, sig, f.auth)
+	case f.enc != nil:
+		return IO.WriteEncryptedSignature(file, sig, *f.enc)
+	}
+
+	return IO.WriteSignature(file, sig)
+}
+
+// Read signature, verified or decrypted if set
+func (f format) readSignature(file string) (Sync.Signature, error) {
+	switch {
+	case f.auth != nil:
+		return IO.ReadSignedSignature(file, f.auth)
+	case f.enc != nil:
+		return IO.ReadEncryptedSignature(file, *f.enc)
+	}
+
+	return IO.ReadSignature(file)
+}
+
+// Write delta, signed or encrypted if set
+func (f format) writeDelta(file string, delta Sync.Stream) error {
+	switch {
+	case f.auth != nil:
+		return IO.WriteSignedDelta(file, delta, f.auth)
+	case f.enc != nil:
+		return IO.WriteEncryptedDelta(file, delta, *f.enc)
+	}
+
+	return IO.WriteDelta(file, delta)
+}
+
+// Read delta, verified or decrypted if set
+func (f format) readDelta(file string) (Sync.Stream, error) {
+	switch {
+	case f.auth != nil:
+		return IO.ReadSignedDelta(file, f.auth)
+	case f.enc != nil:
+		return IO.ReadEncryptedDelta(file, *f.enc)
+	}
 
+	return IO.ReadDelta(file)
 }
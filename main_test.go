@@ -1,6 +1,10 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -71,3 +75,156 @@ func BenchmarkDelta(b *testing.B) {
 	}
 
 }
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	basis := filepath.Join(dir, "mock.txt")
+	signature := filepath.Join(dir, "signature.bin")
+	delta := filepath.Join(dir, "delta.bin")
+	key := filepath.Join(dir, "key")
+	other := filepath.Join(dir, "other")
+	os.WriteFile(key, []byte("shared secret"), 0600)
+	os.WriteFile(other, []byte("other secret"), 0600)
+
+	original, _ := os.ReadFile("mock.txt")
+	expected, _ := os.ReadFile("mockV2.txt")
+	os.WriteFile(basis, original, 0644)
+
+	modes := [][3][]string{
+		{{"signature"}, {"delta"}, {"patch"}},
+		{{"signature"}, {"delta", "-basis", basis}, {"patch", "--inplace"}},
+		{{"signature"}, {"delta", "-basis", basis, "-edits"}, {"patch"}},
+		{{"signature"}, {"delta", "-basis", basis, "-bsdiff"}, {"patch"}},
+		{{"signature", "-seed"}, {"delta"}, {"patch"}},
+		{{"signature", "-cache", filepath.Join(dir, "cache")}, {"delta"}, {"patch"}},
+		{{"signature", "-seed", "-cache", filepath.Join(dir, "cache")}, {"delta"}, {"patch"}},
+		{{"signature", "-key", key}, {"delta", "-key", key}, {"patch", "-key", key}},
+		{{"signature", "-secret", key}, {"delta", "-secret", key}, {"patch", "-secret", key}},
+	}
+
+	for _, mode := range modes {
+		os.WriteFile(basis, original, 0644)
+		steps := [][]string{
+			append(mode[0], basis, signature),
+			append(mode[1], signature, "mockV2.txt", delta),
+			append(mode[2], basis, delta),
+		}
+
+		for _, step := range steps {
+			if err := run(step); err != nil {
+				t.Fatalf("Expected command %v without errors, got %v", step, err)
+			}
+		}
+
+		patched, _ := os.ReadFile(basis)
+		if !bytes.Equal(expected, patched) {
+			t.Errorf("Expected basis patched to mockV2.txt using %v", mode)
+		}
+	}
+
+	if err := run([]string{"patch", "-key", other, basis, delta}); err == nil {
+		t.Errorf("Expected patch to reject delta signed with other key")
+	}
+
+	if err := run([]string{"patch", "-secret", other, basis, delta}); err == nil {
+		t.Errorf("Expected patch to reject delta encrypted with other key")
+	}
+
+	stat := []string{"stat", "-basis", basis, "-secret", key, signature, "mockV2.txt"}
+	if err := run(stat); err != nil {
+		t.Errorf("Expected command %v without errors, got %v", stat, err)
+	}
+
+	if err := run([]string{"delta", "-bsdiff", signature, "mockV2.txt", delta}); err == nil {
+		t.Errorf("Expected -bsdiff without -basis rejected")
+	}
+
+	if err := run([]string{"unknown"}); err == nil {
+		t.Errorf("Expected usage error for unknown command")
+	}
+}
+
+func TestRepositoryCommands(t *testing.T) {
+	dir := t.TempDir()
+	repository := filepath.Join(dir, "repo")
+	output := filepath.Join(dir, "checkout.txt")
+
+	steps := [][]string{
+		{"commit", repository, "mock.txt"},
+		{"commit", "-rebase", "1", repository, "mockV2.txt"},
+		{"log", repository},
+		{"checkout", repository, "1", output},
+		{"prune", "-last", "2", repository},
+		{"prune", "-last", "1", repository},
+		{"scrub", repository},
+	}
+
+	for _, step := range steps {
+		if err := run(step); err != nil {
+			t.Fatalf("Expected command %v without errors, got %v", step, err)
+		}
+	}
+
+	expected, _ := os.ReadFile("mock.txt")
+	checkout, _ := os.ReadFile(output)
+	if !bytes.Equal(expected, checkout) {
+		t.Errorf("Expected version 1 checked out equal to mock.txt")
+	}
+
+	if err := run([]string{"checkout", repository, "1", output}); err == nil {
+		t.Errorf("Expected error checking out pruned version")
+	}
+
+	if err := run([]string{"prune", repository}); err == nil {
+		t.Errorf("Expected prune without retention flags rejected")
+	}
+
+	if err := run([]string{"checkout", repository, "3", output}); err == nil {
+		t.Errorf("Expected error checking out missing version")
+	}
+}
+
+// Report delta stream size for each literal compression over test corpus
+func BenchmarkDeltaSize(b *testing.B) {
+	readme, _ := os.ReadFile("README.md")
+	// Rewrite requirements section, keeping the rest
+	edited := bytes.Replace(readme, []byte("Requirements"), []byte("Requirements and constraints for the library"), -1)
+	edited = bytes.Replace(edited, []byte("chunk"), []byte("block"), -1)
+	// Append reformatted copy of the description, new literal similar to matched blocks
+	edited = append(edited, bytes.Replace(readme[:1200], []byte(" "), []byte("  "), -1)...)
+
+	v1, _ := os.ReadFile("mock.txt")
+	v2, _ := os.ReadFile("mockV2.txt")
+	corpus := map[string][2][]byte{
+		"mock":   {v1, v2},
+		"readme": {readme, edited},
+	}
+
+	compressions := map[string]Sync.Compression{
+		"none":    Sync.CompressNone,
+		"deflate": Sync.CompressDeflate,
+		"zstd":    Sync.CompressZstd,
+	}
+
+	for file, versions := range corpus {
+		for name, compression := range compressions {
+			b.Run(file+"/"+name, func(b *testing.B) {
+				b.StopTimer() // We are analyzing encoded size, not compression time
+				sync := Sync.New(1<<4, Sync.WithCompression(compression))
+				sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(versions[0])))
+				delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(versions[1])))
+
+				b.StartTimer()
+				var size int
+				for i := 0; i < b.N; i++ {
+					var encoded bytes.Buffer
+					IO.EncodeDelta(&encoded, delta, Sync.Checkpoint{})
+					size = encoded.Len()
+				}
+
+				b.ReportMetric(float64(size), "delta-bytes")
+				b.ReportMetric(float64(len(versions[1])), "file-bytes")
+			})
+		}
+	}
+}
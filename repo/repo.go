@@ -0,0 +1,286 @@
+// Package repo store a file history as full base versions plus deltas
+// against the previous version. Bases are kept in a chunk store, so bases
+// share unchanged blocks, and they are taken periodically to keep short
+// the chain of deltas replayed on checkout.
+package repo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/geolffreym/rolling-sync/fileio"
+	"github.com/geolffreym/rolling-sync/store"
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Repository layout under root directory
+const (
+	storeDir  = "store"
+	deltasDir = "deltas"
+	logFile   = "log"
+)
+
+// Default deltas between full base versions
+const RebaseInterval = 8
+
+// ErrNoVersion is returned when a version does not exist
+var ErrNoVersion = errors.New("version not found")
+
+// Version describe a committed file version
+type Version struct {
+	Number   int       // Version number, starting at 1
+	Time     time.Time // Commit time
+	Size     int64     // File size
+	Stored   int64     // Delta size, or file size for base versions
+	Base     bool      // Stored in full instead of delta against previous version
+	Checksum string    // Strong checksum of the complete file
+}
+
+type Repo struct {
+	root     string
+	store    *store.Store
+	versions []Version
+	rebase   int
+}
+
+// Option customize Repo behavior
+type Option func(*Repo)
+
+// Set max deltas between base versions, checkout replay at most this deltas
+func WithRebase(interval int) Option {
+	return func(r *Repo) {
+		r.rebase = interval
+	}
+}
+
+// Open repository at root directory, creating it if needed
+func Open(root string, options ...Option) (*Repo, error) {
+	if err := os.MkdirAll(filepath.Join(root, deltasDir), 0755); err != nil {
+		return nil, err
+	}
+
+	chunks, err := store.Open(filepath.Join(root, storeDir))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Repo{root: root, store: chunks, rebase: RebaseInterval}
+	for _, option := range options {
+		option(r)
+	}
+
+	f, err := os.Open(filepath.Join(root, logFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(&r.versions); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Return committed versions, oldest first
+func (r *Repo) Log() []Version {
+	return append([]Version{}, r.versions...)
+}
+
+// Return position of version in log, version numbers could have gaps after prune
+func (r *Repo) find(number int) (int, bool) {
+	i := sort.Search(len(r.versions), func(i int) bool { return r.versions[i].Number >= number })
+	return i, i < len(r.versions) && r.versions[i].Number == number
+}
+
+// Return latest version number, 0 if none
+func (r *Repo) latest() int {
+	if len(r.versions) == 0 {
+		return 0
+	}
+
+	return r.versions[len(r.versions)-1].Number
+}
+
+// Return base version name in chunk store
+func baseName(number int) string {
+	return fmt.Sprintf("base-%d", number)
+}
+
+// Return delta file path for version
+func (r *Repo) deltaPath(number int) string {
+	return filepath.Join(r.root, deltasDir, fmt.Sprint(number))
+}
+
+// Persist versions log atomically
+func (r *Repo) save() error {
+	f, err := fileio.CreateAtomic(filepath.Join(r.root, logFile))
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(r.versions); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// Commit data as new version, stored as delta against latest version
+// unless the chain since last base reach rebase interval or delta is not smaller.
+func (r *Repo) Commit(data io.Reader) (Version, error) {
+	target, err := io.ReadAll(data)
+	if err != nil {
+		return Version{}, err
+	}
+
+	version := Version{
+		Number:   r.latest() + 1,
+		Time:     time.Now(),
+		Size:     int64(len(target)),
+		Checksum: store.ID(target),
+	}
+
+	stored, err := r.commitDelta(version, target)
+	if err != nil {
+		return version, err
+	}
+
+	version.Stored = stored
+	if stored < 0 {
+		// Full version keep chunks of its own size
+		blockSize := sync.AutoBlockSize(version.Size)
+		if _, err := r.store.Add(baseName(version.Number), bytes.NewReader(target), blockSize); err != nil {
+			return version, err
+		}
+
+		version.Base = true
+		version.Stored = version.Size
+	}
+
+	r.versions = append(r.versions, version)
+	if err := r.save(); err != nil {
+		r.versions = r.versions[:len(r.versions)-1]
+		return version, err
+	}
+
+	return version, nil
+}
+
+// Write delta against previous version, return delta size or -1 if a base is needed
+func (r *Repo) commitDelta(version Version, target []byte) (int64, error) {
+	if len(r.versions) == 0 {
+		return -1, nil
+	}
+
+	length, err := r.chain(r.latest())
+	if err != nil || length >= r.rebase {
+		return -1, err
+	}
+
+	var previous bytes.Buffer
+	if err := r.Checkout(r.latest(), &previous); err != nil {
+		return -1, err
+	}
+
+	basis := previous.Bytes()
+	s := sync.New(0, sync.WithFileSize(int64(len(basis))), sync.WithBasis(bytes.NewReader(basis)))
+	sig := s.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	delta := s.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+
+	var encoded bytes.Buffer
+	if err := fileio.EncodeDelta(&encoded, delta, sync.Checkpoint{}); err != nil {
+		return -1, err
+	}
+
+	if int64(encoded.Len()) >= version.Size {
+		return -1, nil
+	}
+
+	f, err := fileio.CreateAtomic(r.deltaPath(version.Number))
+	if err != nil {
+		return -1, err
+	}
+
+	if _, err := f.Write(encoded.Bytes()); err != nil {
+		f.Abort()
+		return -1, err
+	}
+
+	return int64(encoded.Len()), f.Commit()
+}
+
+// Return deltas replayed to checkout version, every delta is
+// against previous version number which is always kept by prune.
+// Fail if the chain is broken before reaching a base version.
+func (r *Repo) chain(number int) (int, error) {
+	length := 0
+	for i, _ := r.find(number); !r.versions[i].Base; i-- {
+		if i == 0 || r.versions[i-1].Number != r.versions[i].Number-1 {
+			return 0, fmt.Errorf("no base version for version %d", number)
+		}
+
+		length++
+	}
+
+	return length, nil
+}
+
+// Write version contents, replaying deltas from closest base version.
+// Every delta and the final output are verified against their checksums.
+func (r *Repo) Checkout(number int, w io.Writer) error {
+	i, ok := r.find(number)
+	if !ok {
+		return ErrNoVersion
+	}
+
+	length, err := r.chain(number)
+	if err != nil {
+		return err
+	}
+
+	base := number - length
+	index, err := r.store.Index(baseName(base))
+	if err != nil {
+		return err
+	}
+
+	var current bytes.Buffer
+	if err := r.store.Restore(index, &current); err != nil {
+		return err
+	}
+
+	for v := base + 1; v <= number; v++ {
+		delta, err := fileio.ReadDelta(r.deltaPath(v))
+		if err != nil {
+			return err
+		}
+
+		var next bytes.Buffer
+		if err := sync.Patch(bytes.NewReader(current.Bytes()), delta, &next); err != nil {
+			return err
+		}
+
+		current = next
+	}
+
+	if store.ID(current.Bytes()) != r.versions[i].Checksum {
+		return fmt.Errorf("version %d checksum mismatch", number)
+	}
+
+	_, err = w.Write(current.Bytes())
+	return err
+}
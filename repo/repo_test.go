@@ -0,0 +1,118 @@
+package repo
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+// Return versions of mock file, each one editing the previous one
+func mockVersions(t *testing.T, count int) [][]byte {
+	data, err := os.ReadFile("../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := [][]byte{data}
+	for i := 1; i < count; i++ {
+		previous := versions[i-1]
+		at := (i * 397) % len(previous)
+		edited := append(append(append([]byte{}, previous[:at]...), []byte("edited ")...), previous[at:]...)
+		versions = append(versions, edited)
+	}
+
+	return versions
+}
+
+func TestCommitCheckout(t *testing.T) {
+	root := t.TempDir()
+	r, err := Open(root, WithRebase(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions := mockVersions(t, 7)
+	for _, data := range versions {
+		if _, err := r.Commit(bytes.NewReader(data)); err != nil {
+			t.Fatalf("Expected commit without errors, got %v", err)
+		}
+	}
+
+	// Reopened repository keep log
+	r, _ = Open(root, WithRebase(3))
+	log := r.Log()
+	if len(log) != len(versions) {
+		t.Fatalf("Expected %d versions in log, got %d", len(versions), len(log))
+	}
+
+	for i, version := range log {
+		// Base every 3 deltas
+		if version.Base != (i%4 == 0) {
+			t.Errorf("Expected version %d base %v", version.Number, i%4 == 0)
+		}
+
+		if version.Size != int64(len(versions[i])) {
+			t.Errorf("Expected version %d size %d, got %d", version.Number, len(versions[i]), version.Size)
+		}
+
+		if !version.Base && version.Stored >= version.Size/4 {
+			t.Errorf("Expected version %d delta smaller than file, got %d", version.Number, version.Stored)
+		}
+
+		var out bytes.Buffer
+		if err := r.Checkout(version.Number, &out); err != nil || !bytes.Equal(out.Bytes(), versions[i]) {
+			t.Errorf("Expected version %d checked out, got %v", version.Number, err)
+		}
+	}
+
+	if err := r.Checkout(len(versions)+1, &bytes.Buffer{}); !errors.Is(err, ErrNoVersion) {
+		t.Errorf("Expected missing version error, got %v", err)
+	}
+}
+
+func TestBaseSharedChunks(t *testing.T) {
+	r, _ := Open(t.TempDir(), WithRebase(0))
+	data := bytes.Repeat([]byte("i am here guys how are you doing "), 1000)
+	r.Commit(bytes.NewReader(data))
+	first := r.store.Len()
+	r.Commit(bytes.NewReader(append(data, []byte("added")...)))
+
+	if !r.Log()[1].Base {
+		t.Fatal("Expected every version stored as base without rebase interval")
+	}
+
+	if r.store.Len() > 2*first {
+		t.Errorf("Expected bases to share chunks, got %d chunks from %d", r.store.Len(), first)
+	}
+}
+
+func TestCommitUnrelated(t *testing.T) {
+	r, _ := Open(t.TempDir())
+	r.Commit(bytes.NewReader(bytes.Repeat([]byte("a"), 4096)))
+	r.Commit(bytes.NewReader(bytes.Repeat([]byte("b"), 16)))
+
+	// Delta is not smaller than a short unrelated file
+	if !r.Log()[1].Base {
+		t.Errorf("Expected version stored as base when delta is not smaller")
+	}
+}
+
+func TestCheckoutBrokenChain(t *testing.T) {
+	r, _ := Open(t.TempDir())
+	for _, data := range mockVersions(t, 3) {
+		r.Commit(bytes.NewReader(data))
+	}
+
+	// Damaged metadata, base version lost
+	r.versions[0].Base = false
+	if err := r.Checkout(3, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error checking out version without base")
+	}
+
+	// Previous version of delta missing
+	r.versions = r.versions[1:]
+	if err := r.Checkout(3, &bytes.Buffer{}); err == nil {
+		t.Errorf("Expected error checking out version with broken chain")
+	}
+}
@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/geolffreym/rolling-sync/store"
+	"github.com/geolffreym/rolling-sync/sync"
+)
+
+// Policy select versions retained by Prune, latest version is always retained
+type Policy struct {
+	Last   int // Keep last N versions
+	Daily  int // Keep latest version of each of the last N days with versions
+	Weekly int // Keep latest version of each of the last N weeks with versions
+}
+
+// Return version numbers retained by policy
+func (p Policy) retain(versions []Version) map[int]bool {
+	retained := make(map[int]bool)
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		version := versions[i]
+		t := version.Time.UTC()
+		day := t.Format("2006-01-02")
+		year, week := t.ISOWeek()
+		isoWeek := fmt.Sprintf("%d-%d", year, week)
+
+		if i == len(versions)-1 || len(versions)-1-i < p.Last {
+			retained[version.Number] = true
+		}
+
+		// Newest first, so the first version found for each period is the latest one
+		if !days[day] && len(days) < p.Daily {
+			days[day] = true
+			retained[version.Number] = true
+		}
+
+		if !weeks[isoWeek] && len(weeks) < p.Weekly {
+			weeks[isoWeek] = true
+			retained[version.Number] = true
+		}
+	}
+
+	return retained
+}
+
+// Remove versions not retained by policy, return removed versions.
+// Retained versions depending on a removed one are stored as bases first,
+// then chunks no longer referenced are garbage collected.
+func (r *Repo) Prune(policy Policy) ([]Version, error) {
+	retained := policy.retain(r.versions)
+	var kept, removed, rebased []Version
+
+	for _, version := range r.versions {
+		if !retained[version.Number] {
+			removed = append(removed, version)
+			continue
+		}
+
+		// Deltas are against previous version, rebase if it is going away
+		if !version.Base && !retained[version.Number-1] {
+			if err := r.rebaseVersion(version.Number); err != nil {
+				return nil, err
+			}
+
+			rebased = append(rebased, version)
+			version.Base = true
+			version.Stored = version.Size
+		}
+
+		kept = append(kept, version)
+	}
+
+	// Log is saved before removing anything, a crash could only leak files
+	r.versions = kept
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+
+	for _, version := range append(removed, rebased...) {
+		if err := os.Remove(r.deltaPath(version.Number)); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+	}
+
+	for _, version := range removed {
+		if !version.Base {
+			continue
+		}
+
+		if err := r.store.Remove(baseName(version.Number)); err != nil && err != store.ErrNotFound {
+			return removed, err
+		}
+	}
+
+	_, err := r.store.GC()
+	return removed, err
+}
+
+// Store version in full as base
+func (r *Repo) rebaseVersion(number int) error {
+	var data bytes.Buffer
+	if err := r.Checkout(number, &data); err != nil {
+		return err
+	}
+
+	blockSize := sync.AutoBlockSize(int64(data.Len()))
+	_, err := r.store.Add(baseName(number), &data, blockSize)
+	return err
+}
+
+// Scrub every chunk in repository store, see store.Scrub
+func (r *Repo) Scrub() (store.Report, error) {
+	return r.store.Scrub()
+}
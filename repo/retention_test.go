@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Return retained version numbers sorted
+func retainedNumbers(retained map[int]bool) []int {
+	var numbers []int
+	for number := range retained {
+		numbers = append(numbers, number)
+	}
+
+	sort.Ints(numbers)
+	return numbers
+}
+
+func TestPolicyRetain(t *testing.T) {
+	monday := time.Date(2022, 5, 2, 10, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	times := []time.Time{
+		monday.Add(-7 * day),          // 1 previous week
+		monday.Add(-6 * day),          // 2 previous week, latest
+		monday,                        // 3
+		monday.Add(time.Hour),         // 4 monday latest
+		monday.Add(day),               // 5 tuesday
+		monday.Add(2 * day),           // 6
+		monday.Add(2*day + time.Hour), // 7 wednesday latest
+	}
+
+	var versions []Version
+	for i, at := range times {
+		versions = append(versions, Version{Number: i + 1, Time: at})
+	}
+
+	cases := []struct {
+		policy   Policy
+		expected []int
+	}{
+		{Policy{}, []int{7}},
+		{Policy{Last: 3}, []int{5, 6, 7}},
+		{Policy{Daily: 2}, []int{5, 7}},
+		{Policy{Daily: 3}, []int{4, 5, 7}},
+		{Policy{Weekly: 2}, []int{2, 7}},
+		{Policy{Last: 1, Daily: 1, Weekly: 3}, []int{2, 7}},
+	}
+
+	for _, c := range cases {
+		if retained := retainedNumbers(c.policy.retain(versions)); !reflect.DeepEqual(retained, c.expected) {
+			t.Errorf("Expected %+v to retain %v, got %v", c.policy, c.expected, retained)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	root := t.TempDir()
+	r, _ := Open(root, WithRebase(3))
+	versions := mockVersions(t, 7)
+	for _, data := range versions {
+		r.Commit(bytes.NewReader(data))
+	}
+
+	// Version 6 is a delta against removed version 5
+	removed, err := r.Prune(Policy{Last: 2})
+	if err != nil || len(removed) != 5 {
+		t.Fatalf("Expected 5 versions removed, got %d and %v", len(removed), err)
+	}
+
+	log := r.Log()
+	if len(log) != 2 || log[0].Number != 6 || !log[0].Base || log[1].Base {
+		t.Fatalf("Expected version 6 rebased and version 7 kept as delta, got %+v", log)
+	}
+
+	for i, version := range log {
+		var out bytes.Buffer
+		if err := r.Checkout(version.Number, &out); err != nil || !bytes.Equal(out.Bytes(), versions[5+i]) {
+			t.Errorf("Expected version %d checked out after prune, got %v", version.Number, err)
+		}
+	}
+
+	deltas, _ := os.ReadDir(filepath.Join(root, "deltas"))
+	if len(deltas) != 1 || deltas[0].Name() != "7" {
+		t.Errorf("Expected only version 7 delta left, got %v", deltas)
+	}
+
+	if names, _ := r.store.Indexes(); !reflect.DeepEqual(names, []string{"base-6"}) {
+		t.Errorf("Expected only version 6 base left, got %v", names)
+	}
+
+	if report, err := r.Scrub(); err != nil || !report.OK() || report.Chunks != r.store.Len() {
+		t.Errorf("Expected healthy store after prune, got %+v and %v", report, err)
+	}
+
+	// Numbers keep growing after prune
+	version, err := r.Commit(bytes.NewReader(versions[0]))
+	if err != nil || version.Number != 8 {
+		t.Errorf("Expected version 8 committed after prune, got %d and %v", version.Number, err)
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Report of chunk store integrity scrub
+type Report struct {
+	Chunks    int      // Chunks checked
+	Corrupted []string // Chunks with data not matching their ID
+	Missing   []string // Referenced chunks not found
+}
+
+// Return true if no corrupted or missing chunk was found
+func (r Report) OK() bool {
+	return len(r.Corrupted) == 0 && len(r.Missing) == 0
+}
+
+// Mark and sweep garbage collection: remove every chunk file not referenced by
+// any index, including chunks leaked by interrupted writes, and rebuild reference
+// counts from indexes. Return removed files count.
+func (s *Store) GC() (int, error) {
+	// Mark
+	refs := make(map[string]int)
+	names, err := s.Indexes()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range names {
+		index, err := s.Index(name)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, id := range index.Chunks {
+			refs[id]++
+		}
+	}
+
+	// Sweep
+	removed := 0
+	err = s.walk(func(path, name string) error {
+		if refs[name] > 0 {
+			return nil
+		}
+
+		removed++
+		return os.Remove(path)
+	})
+
+	if err != nil {
+		return removed, err
+	}
+
+	s.refs = refs
+	return removed, s.save()
+}
+
+// Re-hash every chunk against its ID and check every referenced chunk exists
+func (s *Store) Scrub() (Report, error) {
+	var report Report
+	found := make(map[string]bool)
+	err := s.walk(func(path, name string) error {
+		// Skip temporary files left by interrupted writes
+		if name[0] == '.' {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		report.Chunks++
+		found[name] = true
+		if ID(data) != name {
+			report.Corrupted = append(report.Corrupted, name)
+		}
+
+		return nil
+	})
+
+	for id := range s.refs {
+		if !found[id] {
+			report.Missing = append(report.Missing, id)
+		}
+	}
+
+	sort.Strings(report.Corrupted)
+	sort.Strings(report.Missing)
+	return report, err
+}
+
+// Call fn with path and file name of every file in chunks directory
+func (s *Store) walk(fn func(path, name string) error) error {
+	return filepath.WalkDir(filepath.Join(s.root, chunksDir), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		return fn(path, d.Name())
+	})
+}
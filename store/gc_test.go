@@ -0,0 +1,55 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGC(t *testing.T) {
+	root := t.TempDir()
+	s, _ := Open(root)
+	index, _ := s.Add("file", bytes.NewReader([]byte("shared blockonly in file")), 12)
+
+	// Chunk leaked by an interrupted write, and reference counts lost
+	leaked := []byte("leaked chunk")
+	s.write(ID(leaked), leaked)
+	os.WriteFile(filepath.Join(root, "chunks", ".leaked.123.tmp"), leaked, 0644)
+	s.refs = map[string]int{}
+
+	removed, err := s.GC()
+	if err != nil || removed != 2 {
+		t.Errorf("Expected leaked chunk and temporary file removed, got %d and %v", removed, err)
+	}
+
+	if s.Refs(index.Chunks[0]) != 1 || s.Refs(index.Chunks[1]) != 1 || s.Len() != 2 {
+		t.Errorf("Expected reference counts rebuilt from indexes, got %v", s.refs)
+	}
+
+	var out bytes.Buffer
+	if err := s.Restore(index, &out); err != nil || out.String() != "shared blockonly in file" {
+		t.Errorf("Expected referenced chunks kept, got %v", err)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	root := t.TempDir()
+	s, _ := Open(root)
+	index, _ := s.Add("file", bytes.NewReader([]byte("hello world, how are you")), 8)
+
+	report, err := s.Scrub()
+	if err != nil || !report.OK() || report.Chunks != 3 {
+		t.Fatalf("Expected 3 healthy chunks, got %+v and %v", report, err)
+	}
+
+	corrupted, missing := index.Chunks[0], index.Chunks[2]
+	os.WriteFile(s.path(corrupted), []byte("bit rot!"), 0644)
+	os.Remove(s.path(missing))
+
+	report, _ = s.Scrub()
+	if !reflect.DeepEqual(report.Corrupted, []string{corrupted}) || !reflect.DeepEqual(report.Missing, []string{missing}) {
+		t.Errorf("Expected corrupted and missing chunks reported, got %+v", report)
+	}
+}
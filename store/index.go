@@ -0,0 +1,102 @@
+package store
+
+import (
+	"encoding/gob"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/geolffreym/rolling-sync/fileio"
+)
+
+// Index describe a file as a sequence of chunk IDs
+type Index struct {
+	Size      int64    // File size in bytes
+	BlockSize int      // Chunk size, last chunk could be smaller
+	Chunks    []string // Chunk IDs in file order
+}
+
+// Write index file atomically
+func WriteIndex(file string, index Index) error {
+	f, err := fileio.CreateAtomic(file)
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(index); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// Read index file
+func ReadIndex(file string) (Index, error) {
+	var index Index
+	f, err := os.Open(file)
+	if err != nil {
+		return index, err
+	}
+
+	defer f.Close()
+	err = gob.NewDecoder(f).Decode(&index)
+	return index, err
+}
+
+// ErrInvalidName is returned for empty index names
+var ErrInvalidName = errors.New("invalid index name")
+
+// Return index file path, names are escaped so any name keep in indexes directory.
+// Leading dot is escaped too, dot files are temporary ones.
+func (s *Store) indexPath(name string) (string, error) {
+	if name == "" {
+		return "", ErrInvalidName
+	}
+
+	escaped := url.PathEscape(name)
+	if escaped[0] == '.' {
+		escaped = "%2E" + escaped[1:]
+	}
+
+	return filepath.Join(s.root, indexesDir, escaped), nil
+}
+
+// Return index saved under name
+func (s *Store) Index(name string) (Index, error) {
+	path, err := s.indexPath(name)
+	if err != nil {
+		return Index{}, err
+	}
+
+	index, err := ReadIndex(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return index, ErrNotFound
+	}
+
+	return index, err
+}
+
+// Return sorted names of saved indexes
+func (s *Store) Indexes() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.root, indexesDir))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name, err := url.PathUnescape(entry.Name())
+		// Skip temporary files left by interrupted writes
+		if err != nil || entry.Name()[0] == '.' {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIndexReadWrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "index")
+	index := Index{Size: 12, BlockSize: 8, Chunks: []string{ID([]byte("hello wo")), ID([]byte("rld!"))}}
+
+	if err := WriteIndex(file, index); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadIndex(file)
+	if err != nil || !reflect.DeepEqual(read, index) {
+		t.Errorf("Expected written index equal to read index, got %v", err)
+	}
+}
+
+func TestIndexNames(t *testing.T) {
+	s, _ := Open(t.TempDir())
+	for _, name := range []string{"b/c", "a", "../escape", ".."} {
+		if _, err := s.Add(name, bytes.NewReader([]byte("data")), 8); err != nil {
+			t.Fatalf("Expected index %q added, got %v", name, err)
+		}
+	}
+
+	names, err := s.Indexes()
+	if err != nil || !reflect.DeepEqual(names, []string{"..", "../escape", "a", "b/c"}) {
+		t.Errorf("Expected escaped names listed back, got %v %v", names, err)
+	}
+
+	if _, err := s.Add("", bytes.NewReader([]byte("data")), 8); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Expected invalid name error for empty name, got %v", err)
+	}
+}
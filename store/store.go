@@ -0,0 +1,283 @@
+// Package store implement a content addressed chunk store on local filesystem.
+// Chunks are keyed by their strong checksum, same as signature tables, so
+// identical blocks across versions and files are stored only once.
+package store
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/geolffreym/rolling-sync/fileio"
+)
+
+// Store layout under root directory
+const (
+	chunksDir  = "chunks"
+	indexesDir = "indexes"
+	refsFile   = "refs"
+)
+
+// ErrNotFound is returned when a chunk or index does not exist
+var ErrNotFound = errors.New("not found in store")
+
+// ErrCorrupted is returned when chunk data does not match its ID
+type ErrCorrupted struct {
+	ID     string
+	Actual string
+}
+
+func (e *ErrCorrupted) Error() string {
+	return fmt.Sprintf("chunk %s corrupted, data hash %s", e.ID, e.Actual)
+}
+
+// Store keep chunks in sharded directories: chunks/ab/cd/abcd...
+// plus chunk reference counts, a chunk is removed when no index use it.
+type Store struct {
+	root string
+	refs map[string]int
+}
+
+// Return chunk ID for data, the hex SHA-1 used as signature strong checksum
+func ID(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Open store at root directory, creating it if needed
+func Open(root string) (*Store, error) {
+	for _, dir := range []string{chunksDir, indexesDir} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{root: root, refs: make(map[string]int)}
+	f, err := os.Open(filepath.Join(root, refsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	if err := gob.NewDecoder(f).Decode(&s.refs); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Return chunk file path
+func (s *Store) path(id string) string {
+	return filepath.Join(s.root, chunksDir, id[:2], id[2:4], id)
+}
+
+// Persist reference counts atomically
+func (s *Store) save() error {
+	f, err := fileio.CreateAtomic(filepath.Join(s.root, refsFile))
+	if err != nil {
+		return err
+	}
+
+	if err := gob.NewEncoder(f).Encode(s.refs); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// Store chunk if missing and add a reference to it, return chunk ID.
+// References are kept in memory until saved.
+func (s *Store) put(data []byte) (string, error) {
+	id := ID(data)
+	if s.refs[id] == 0 {
+		if err := s.write(id, data); err != nil {
+			return "", err
+		}
+	}
+
+	s.refs[id]++
+	return id, nil
+}
+
+// Write chunk file atomically
+func (s *Store) write(id string, data []byte) error {
+	path := s.path(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := fileio.CreateAtomic(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+
+	return f.Commit()
+}
+
+// Return chunk data verified against its ID
+func (s *Store) Get(id string) ([]byte, error) {
+	if len(id) != sha1.Size*2 {
+		return nil, ErrNotFound
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if actual := ID(data); actual != id {
+		return nil, &ErrCorrupted{ID: id, Actual: actual}
+	}
+
+	return data, nil
+}
+
+// Return true if chunk is stored
+func (s *Store) Has(id string) bool {
+	return s.refs[id] > 0
+}
+
+// Return references count for chunk
+func (s *Store) Refs(id string) int {
+	return s.refs[id]
+}
+
+// Return stored chunks count
+func (s *Store) Len() int {
+	return len(s.refs)
+}
+
+// Remove a reference to every chunk, chunks no longer referenced are deleted
+func (s *Store) Release(ids []string) error {
+	for _, id := range ids {
+		if s.refs[id] == 0 {
+			continue
+		}
+
+		s.refs[id]--
+		if s.refs[id] > 0 {
+			continue
+		}
+
+		delete(s.refs, id)
+		if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return s.save()
+}
+
+// Split reader in blocks, store them and save index under name replacing any
+// previous one. Return index describing data as sequence of chunk IDs.
+func (s *Store) Add(name string, r io.Reader, blockSize int) (Index, error) {
+	path, err := s.indexPath(name)
+	if err != nil {
+		return Index{}, err
+	}
+
+	if blockSize <= 0 {
+		return Index{}, errors.New("invalid block size")
+	}
+
+	previous, err := s.Index(name)
+	if err != nil && err != ErrNotFound {
+		return Index{}, err
+	}
+
+	index, err := s.split(r, blockSize)
+	if err == nil {
+		// References are saved before index, a crash could only leak chunks
+		err = s.save()
+	}
+
+	if err == nil {
+		err = WriteIndex(path, index)
+	}
+
+	// References taken by an index that was not saved are dropped
+	if err != nil {
+		s.Release(index.Chunks)
+		return Index{}, err
+	}
+
+	// Chunks of replaced index are released after new index is saved
+	return index, s.Release(previous.Chunks)
+}
+
+// Split reader in blocks and store them, return index with chunks stored
+// so far even on error, their references must be released.
+func (s *Store) split(r io.Reader, blockSize int) (Index, error) {
+	index := Index{BlockSize: blockSize}
+	block := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, block)
+		if n > 0 {
+			id, err := s.put(block[:n])
+			if err != nil {
+				return index, err
+			}
+
+			index.Chunks = append(index.Chunks, id)
+			index.Size += int64(n)
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return index, nil
+		}
+
+		if err != nil {
+			return index, err
+		}
+	}
+}
+
+// Remove index releasing its chunks
+func (s *Store) Remove(name string) error {
+	index, err := s.Index(name)
+	if err != nil {
+		return err
+	}
+
+	path, _ := s.indexPath(name)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	return s.Release(index.Chunks)
+}
+
+// Write data described by index, every chunk is verified
+func (s *Store) Restore(index Index, w io.Writer) error {
+	for _, id := range index.Chunks {
+		data, err := s.Get(id)
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAddDeduplicate(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v1 := bytes.Repeat([]byte("i am here guys how are you doing"), 8)
+	v2 := append(append([]byte{}, v1...), []byte("added")...)
+
+	first, err := s.Add("v1", bytes.NewReader(v1), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.Add("v2", bytes.NewReader(v2), 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every v1 block is the same, v2 add a short last block
+	if s.Len() != 2 || len(first.Chunks) != 8 || len(second.Chunks) != 9 {
+		t.Errorf("Expected 2 chunks shared by 17 references, got %d chunks", s.Len())
+	}
+
+	if s.Refs(first.Chunks[0]) != 16 || s.Refs(second.Chunks[8]) != 1 {
+		t.Errorf("Expected reference counts for shared and new chunks, got %d and %d",
+			s.Refs(first.Chunks[0]), s.Refs(second.Chunks[8]))
+	}
+
+	if second.Size != int64(len(v2)) {
+		t.Errorf("Expected index size %d, got %d", len(v2), second.Size)
+	}
+
+	var out bytes.Buffer
+	if err := s.Restore(second, &out); err != nil || !bytes.Equal(out.Bytes(), v2) {
+		t.Errorf("Expected v2 restored from chunks, got %v", err)
+	}
+}
+
+func TestShardedChunks(t *testing.T) {
+	root := t.TempDir()
+	s, _ := Open(root)
+	index, _ := s.Add("file", bytes.NewReader([]byte("hello world")), 8)
+
+	id := index.Chunks[0]
+	if _, err := os.Stat(filepath.Join(root, "chunks", id[:2], id[2:4], id)); err != nil {
+		t.Errorf("Expected chunk stored in sharded directory, got %v", err)
+	}
+
+	if id != ID([]byte("hello wo")) {
+		t.Errorf("Expected chunk keyed by strong checksum")
+	}
+}
+
+func TestRemoveRelease(t *testing.T) {
+	root := t.TempDir()
+	s, _ := Open(root)
+	a, _ := s.Add("a", bytes.NewReader([]byte("shared blockonly in a")), 12)
+	b, _ := s.Add("b", bytes.NewReader([]byte("shared blockonly in b")), 12)
+
+	if err := s.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Has(a.Chunks[1]) || !s.Has(a.Chunks[0]) || s.Refs(b.Chunks[0]) != 1 {
+		t.Errorf("Expected only chunks not used by b removed")
+	}
+
+	if _, err := s.Get(a.Chunks[1]); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected released chunk deleted, got %v", err)
+	}
+
+	// Replacing index release old chunks
+	s.Add("b", bytes.NewReader([]byte("new contents")), 12)
+	if s.Has(b.Chunks[0]) || s.Has(b.Chunks[1]) || s.Len() != 1 {
+		t.Errorf("Expected replaced index chunks released, got %d chunks", s.Len())
+	}
+
+	// Reference counts persist
+	reopened, err := Open(root)
+	if err != nil || reopened.Len() != 1 {
+		t.Errorf("Expected reference counts read back, got %v", err)
+	}
+
+	if err := s.Remove("a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected missing index error, got %v", err)
+	}
+}
+
+func TestAddRollback(t *testing.T) {
+	root := t.TempDir()
+	s, _ := Open(root)
+	a, _ := s.Add("a", bytes.NewReader([]byte("shared block")), 12)
+
+	// Index can't be written over a directory
+	path, _ := s.indexPath("b")
+	os.MkdirAll(filepath.Join(path, "busy"), 0755)
+	if _, err := s.Add("b", bytes.NewReader([]byte("shared blockonly in b")), 12); err == nil {
+		t.Fatal("Expected error writing index over a directory")
+	}
+
+	if s.Refs(a.Chunks[0]) != 1 || s.Len() != 1 {
+		t.Errorf("Expected references of unsaved index dropped, got %d refs and %d chunks", s.Refs(a.Chunks[0]), s.Len())
+	}
+
+	if _, err := s.Get(ID([]byte("only in b"))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected chunks only used by unsaved index deleted, got %v", err)
+	}
+
+	reopened, _ := Open(root)
+	if reopened.Refs(a.Chunks[0]) != 1 {
+		t.Errorf("Expected saved reference counts rolled back, got %d", reopened.Refs(a.Chunks[0]))
+	}
+}
+
+func TestCorruptedChunk(t *testing.T) {
+	root := t.TempDir()
+	s, _ := Open(root)
+	index, _ := s.Add("file", bytes.NewReader([]byte("hello world")), 8)
+
+	id := index.Chunks[0]
+	os.WriteFile(filepath.Join(root, "chunks", id[:2], id[2:4], id), []byte("hello wx"), 0644)
+
+	var corrupted *ErrCorrupted
+	if err := s.Restore(index, &bytes.Buffer{}); !errors.As(err, &corrupted) || corrupted.ID != id {
+		t.Errorf("Expected corrupted chunk detected, got %v", err)
+	}
+}
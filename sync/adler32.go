@@ -6,11 +6,15 @@ package sync
 // The sums are done modulo 65521 (the largest prime number smaller than 2^16).
 const M = 65521
 
+// Bytes summed before reducing modulo M, sums can't overflow 64 bits
+const reduceInterval = 1 << 16
+
 type Adler32 struct {
 	window []byte // A fixed size array of temporary evaluated bytes
 	count  int    // Last position
 	old    uint8  // Last element rolled out
-	a, b   uint16 // adler32 formula
+	a, b   uint32 // adler32 formula, kept modulo M
+	table  *table // Seeded byte values, nil for plain adler32
 }
 
 // Factory function
@@ -23,18 +27,48 @@ func NewAdler32() Adler32 {
 	}
 }
 
+// Factory function for adler32 summing seeded byte values, see NewSeed.
+// Empty seed return plain adler32.
+func NewSeededAdler32(seed []byte) Adler32 {
+	h := NewAdler32()
+	h.table = seedTable(seed)
+	return h
+}
+
+// Return value summed for byte
+func (h Adler32) value(c byte) uint32 {
+	if h.table == nil {
+		return uint32(c)
+	}
+
+	return h.table[c]
+}
+
 // Calculate initial checksum from byte slice
 func (h Adler32) Write(data []byte) Adler32 {
 	//https://en.wikipedia.org/wiki/Adler-32
 	//https://rsync.samba.org/tech_report/node3.html
-	for index, char := range data {
-		h.a += uint16(char)
-		h.b += uint16(len(data)-index) * uint16(char)
-		h.count++
+	// Sum in 64 bits reducing modulo M once every reduceInterval bytes,
+	// b add a after each byte so every byte is weighted by bytes following it
+	var a, b uint64
+	for len(data) > 0 {
+		chunk := data
+		if len(chunk) > reduceInterval {
+			chunk = chunk[:reduceInterval]
+		}
+
+		for _, char := range chunk {
+			a += uint64(h.value(char))
+			b += a
+		}
+
+		a, b = a%M, b%M
+		h.count += len(chunk)
+		data = data[len(chunk):]
 	}
 
-	h.a %= M
-	h.b %= M
+	h.a = uint32((uint64(h.a) + a) % M)
+	h.b = uint32((uint64(h.b) + b) % M)
 	return h
 }
 
@@ -44,7 +78,7 @@ func (h Adler32) Sum() uint32 {
 	// a =  920 =  0x398  (base 16)
 	// b = 4582 = 0x11E6
 	// Output = 0x11E6 << 16 + 0x398 = 0x11E60398
-	return uint32(h.b)<<16 | uint32(h.a)&0xFFFFF
+	return h.b<<16 | h.a
 }
 
 func (h Adler32) Window() []byte { return h.window }
@@ -53,7 +87,7 @@ func (h Adler32) Removed() uint8 { return h.old }
 
 // Add byte to rolling checksum
 func (h Adler32) RollIn(input byte) Adler32 {
-	h.a = (h.a + uint16(input)) % M
+	h.a = (h.a + h.value(input)) % M
 	h.b = (h.b + h.a) % M
 	// Keep stored windows bytes while get processed
 	h.window = append(h.window, input)
@@ -70,10 +104,28 @@ func (h Adler32) RollOut() Adler32 {
 	}
 
 	h.old = h.window[0]
-	h.a = (h.a - uint16(h.old)) % M
-	h.b = (h.b - (uint16(len(h.window)) * uint16(h.old))) % M
+	// Add M before subtract to keep values positive
+	h.a = (h.a + M - h.value(h.old)) % M
+	h.b = (h.b + M - uint32(len(h.window))%M*h.value(h.old)%M) % M
 	h.window = h.window[1:]
 	h.count--
 
 	return h
 }
+
+// Roll window of size bytes one byte forward, removing out and adding in.
+// Window bytes are not kept, caller keep them eg. as a slice over the whole data.
+func (h Adler32) Roll(out, in byte, size int) Adler32 {
+	h.old = out
+	h.a = (h.a + M - h.value(out) + h.value(in)) % M
+	h.b = (h.b + M - uint32(size)%M*h.value(out)%M + h.a) % M
+	return h
+}
+
+// Write data into empty window at once, like rolling it in byte by byte.
+// Window keep data, it must not be modified later.
+func (h Adler32) fill(data []byte) Adler32 {
+	h = h.Write(data)
+	h.window = data
+	return h
+}
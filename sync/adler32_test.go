@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -91,3 +92,40 @@ func TestRollOut(t *testing.T) {
 	}
 
 }
+
+func TestRollingLargeWindow(t *testing.T) {
+	data := make([]byte, 1<<13)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	// Rolling checksum must match full checksum for any window size
+	for _, size := range []int{1 << 4, 700, 1 << 11} {
+		rolling := NewAdler32()
+		for i, c := range data {
+			rolling = rolling.RollIn(c)
+			if rolling.Count() > size {
+				rolling = rolling.RollOut()
+			}
+
+			if rolling.Count() == size && rolling.Sum() != weak(data[i+1-size:i+1]) {
+				t.Fatalf("Expected rolling checksum equal to full checksum for window %d at %d", size, i)
+			}
+		}
+	}
+}
+
+func TestRoll(t *testing.T) {
+	data := make([]byte, 1<<13)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, seed := range [][]byte{nil, []byte("seed")} {
+		for _, size := range []int{1 << 4, 700, 1 << 11} {
+			rolling := NewSeededAdler32(seed).Write(data[:size])
+			for start := 1; start+size <= len(data); start++ {
+				rolling = rolling.Roll(data[start-1], data[start+size-1], size)
+				if rolling.Sum() != NewSeededAdler32(seed).Write(data[start:start+size]).Sum() {
+					t.Fatalf("Expected rolled checksum equal to full checksum for window %d at %d", size, start)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package sync
+
+import "math"
+
+const (
+	MinBlockSize = 700     // rsync default block size
+	MaxBlockSize = 1 << 17 // rsync max block size, 128 KiB
+)
+
+// Calculate block size for file size using rsync square root rule.
+// Block size is rounded down to a multiple of 8 and clamped to min/max,
+// small files get half size blocks so at least two chunks are generated.
+// Unknown (zero) file size return MinBlockSize.
+func AutoBlockSize(fileSize int64) int {
+	if fileSize <= 0 {
+		return MinBlockSize
+	}
+
+	size := int64(math.Sqrt(float64(fileSize))) &^ 7
+	if size < MinBlockSize {
+		size = MinBlockSize
+	}
+
+	if size > MaxBlockSize {
+		size = MaxBlockSize
+	}
+
+	// Ensure at least two chunks
+	if half := (fileSize + 1) / 2; size > half {
+		size = half
+	}
+
+	return int(size)
+}
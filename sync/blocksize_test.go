@@ -0,0 +1,58 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestAutoBlockSize(t *testing.T) {
+	expected := map[int64]int{
+		0:               MinBlockSize,     // unknown size
+		87:              44,               // at least two chunks
+		1398:            699,              // at least two chunks below min
+		1399:            MinBlockSize,     // min fit in two chunks
+		1 << 20:         1 << 10,          // square root
+		1008*1008 - 1:   1000,             // rounded down to multiple of 8
+		1008 * 1008:     1008,             // multiple of 8 boundary
+		704*704 - 1:     MinBlockSize,     // rounded below min, clamped
+		704 * 704:       704,              // first size above min
+		1 << 18:         MinBlockSize,     // clamped to min
+		1<<34 - 1:       MaxBlockSize - 8, // rounded down below max
+		1 << 34:         MaxBlockSize,     // square root at max
+		131080 * 131080: MaxBlockSize,     // square root above max, clamped
+		1 << 40:         MaxBlockSize,     // clamped to max
+	}
+
+	for fileSize, size := range expected {
+		if got := AutoBlockSize(fileSize); got != size {
+			t.Errorf("Expected block size %d for file size %d, got %d", size, fileSize, got)
+		}
+	}
+}
+
+func TestSignatureBlockSize(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 64)
+	b := bytes.Replace(a, []byte("small"), []byte("big"), 1)
+
+	// Auto block size using file size
+	sig := New(0, WithFileSize(int64(len(a)))).BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	if sig.BlockSize != AutoBlockSize(int64(len(a))) {
+		t.Fatalf("Expected auto block size recorded in signature, got %d", sig.BlockSize)
+	}
+
+	// Delta side use block size from signature
+	delta := New(0).Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+		t.Fatalf("Expected patch without errors, got %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), b) {
+		t.Errorf("Expected patched output equal to target")
+	}
+
+	if literalSize(delta) > 2*sig.BlockSize {
+		t.Errorf("Expected literal data bounded by changed block, got %d bytes", literalSize(delta))
+	}
+}
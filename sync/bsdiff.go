@@ -0,0 +1,173 @@
+package sync
+
+import (
+	"index/suffixarray"
+	"io"
+	"math"
+)
+
+// Match bytes needed to prefer a new match over the current approximate one
+const bsdiffSlack = 8
+
+// Calculate delta using bsdiff algorithm: approximate matches found with a suffix
+// array of basis are emitted as add operations (bytewise difference against basis)
+// and unmatched data as literals (extra). Small constant changes, like shifted
+// addresses in executables, become runs of zeros in add data that compress well.
+// Basis and target are kept in memory.
+// See also: http://www.daemonology.net/bsdiff/
+func (s *Sync) binaryDiff(basis []byte, target []byte) Stream {
+	stream := newStreamer(s.checkpoint, s.compression)
+	index := suffixarray.New(basis)
+
+	scan, length, pos := 0, 0, 0
+	lastScan, lastPos, lastOffset := 0, 0, 0
+
+	for scan < len(target) {
+		oldScore := 0
+		scan += length
+
+		// Look for next match better than extending the current approximate one
+		for scsc := scan; scan < len(target); scan++ {
+			pos, length = longestMatch(index, target[scan:])
+
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < len(basis) && basis[scsc+lastOffset] == target[scsc] {
+					oldScore++
+				}
+			}
+
+			if (length == oldScore && length != 0) || length > oldScore+bsdiffSlack {
+				break
+			}
+
+			if scan+lastOffset < len(basis) && basis[scan+lastOffset] == target[scan] {
+				oldScore--
+			}
+		}
+
+		if length == oldScore && scan != len(target) {
+			continue
+		}
+
+		// Extend forward from last match while at least half bytes match
+		forward, score, best := 0, 0, 0
+		for i := 0; lastScan+i < scan && lastPos+i < len(basis); i++ {
+			if basis[lastPos+i] == target[lastScan+i] {
+				score++
+			}
+
+			if score*2-(i+1) > best*2-forward {
+				best, forward = score, i+1
+			}
+		}
+
+		// Extend backward from new match while at least half bytes match
+		backward := 0
+		if scan < len(target) {
+			score, best = 0, 0
+			for i := 1; scan >= lastScan+i && pos >= i; i++ {
+				if basis[pos-i] == target[scan-i] {
+					score++
+				}
+
+				if score*2-i > best*2-backward {
+					best, backward = score, i
+				}
+			}
+		}
+
+		// Resolve overlap between forward and backward extensions
+		if lastScan+forward > scan-backward {
+			overlap := (lastScan + forward) - (scan - backward)
+			score, best, split := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if target[lastScan+forward-overlap+i] == basis[lastPos+forward-overlap+i] {
+					score++
+				}
+
+				if target[scan-backward+i] == basis[pos-backward+i] {
+					score--
+				}
+
+				if score > best {
+					best, split = score, i+1
+				}
+			}
+
+			forward += split - overlap
+			backward -= split
+		}
+
+		// Add bytewise difference for approximate match, then extra bytes
+		s.addDiff(stream, basis[lastPos:lastPos+forward], target[lastScan:lastScan+forward], lastPos)
+		stream.literal(target[lastScan+forward : scan-backward])
+
+		lastScan = scan - backward
+		lastPos = pos - backward
+		lastOffset = pos - scan
+	}
+
+	return stream.done()
+}
+
+// Add operations for bytewise difference of target against basis starting at pos,
+// split in checkpoint interval sized chunks as literals are
+func (s *Sync) addDiff(stream *streamer, basis, target []byte, pos int) {
+	for len(target) > 0 {
+		size := len(target)
+		if s.checkpoint > 0 && size > s.checkpoint {
+			size = s.checkpoint
+		}
+
+		diff := make([]byte, size)
+		for i := range diff {
+			diff[i] = target[i] - basis[i]
+		}
+
+		stream.add(s.compressOp(stream, Op{Type: OpAdd, Start: int64(pos), Offset: int64(pos + size), Lit: diff}), target[:size])
+		basis, target, pos = basis[size:], target[size:], pos+size
+	}
+}
+
+// Compress add operation data using stream codec
+func (s *Sync) compressOp(stream *streamer, op Op) Op {
+	compressed := stream.compress(op.Lit)
+	op.Lit, op.Size = compressed.Lit, compressed.Size
+	return op
+}
+
+// Return basis position and length of the longest prefix of data found in basis
+func longestMatch(index *suffixarray.Index, data []byte) (int, int) {
+	pos, length := 0, 0
+	// Exponential search for an upper bound then binary search for the length
+	low, high := 0, 1
+	for high <= len(data) {
+		found := index.Lookup(data[:high], 1)
+		if len(found) == 0 {
+			break
+		}
+
+		pos, length, low = found[0], high, high
+		high *= 2
+	}
+
+	if high > len(data) {
+		high = len(data) + 1
+	}
+
+	for low+1 < high {
+		mid := low + (high-low)/2
+		if found := index.Lookup(data[:mid], 1); len(found) > 0 {
+			pos, length, low = found[0], mid, mid
+		} else {
+			high = mid
+		}
+	}
+
+	return pos, length
+}
+
+// Read the whole basis data
+func readAll(basis io.ReaderAt) ([]byte, error) {
+	return io.ReadAll(io.NewSectionReader(basis, 0, math.MaxInt64))
+}
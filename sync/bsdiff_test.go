@@ -0,0 +1,112 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"index/suffixarray"
+	"os"
+	"testing"
+)
+
+func bsdiffDelta(a, b []byte, options ...Option) Stream {
+	options = append(options, WithBasis(bytes.NewReader(a)), WithBSDiff())
+	sync := New(1<<4, options...)
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	return sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+}
+
+func TestBSDiff(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	targets := [][]byte{
+		[]byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash"),
+		[]byte("i here guys how are you doing this is a mall test chunk split and rolling hash"),
+		[]byte("i am here guys how are you doing this is a smell test for chunk split and rolling hash"),
+		[]byte("completely different content"),
+		[]byte(""),
+	}
+
+	for _, b := range targets {
+		delta := bsdiffDelta(a, b)
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+			t.Fatalf("Expected patch without errors, got %v", err)
+		}
+
+		if !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("Expected patched output %q equal to target", out.Bytes())
+		}
+	}
+}
+
+func TestBSDiffCheckpoints(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 8)
+	// Shifted bytes all over target, one long approximate match
+	b := append([]byte{}, a...)
+	for i := 0; i < len(b); i += 10 {
+		b[i]++
+	}
+
+	delta := bsdiffDelta(a, b, WithCheckpoint(1<<6), WithCompression(CompressDeflate))
+	for _, op := range delta.Ops {
+		if op.Type == OpAdd && op.Offset-op.Start > 1<<6 {
+			t.Fatalf("Expected add operations split at checkpoint interval, got %d bytes", op.Offset-op.Start)
+		}
+	}
+
+	if len(delta.Checkpoints) < len(b)>>6-1 {
+		t.Fatalf("Expected checkpoints every 64 bytes, got %d", len(delta.Checkpoints))
+	}
+
+	for _, cp := range delta.Checkpoints {
+		out := bytes.NewBuffer(append([]byte{}, b[:cp.Offset]...))
+		if err := Resume(bytes.NewReader(a), delta, out, cp); err != nil {
+			t.Fatalf("Expected resume from op %d without errors, got %v", cp.Op, err)
+		}
+
+		if !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("Expected resumed output from op %d equal to target", cp.Op)
+		}
+	}
+}
+
+func TestLongestMatch(t *testing.T) {
+	a := []byte("i am here guys how are you doing")
+	pos, length := longestMatch(suffixarray.New(a), []byte("how are you XX"))
+	if pos != 15 || length != 12 {
+		t.Errorf("Expected longest match at 15 with length 12, got %d %d", pos, length)
+	}
+}
+
+// Fixtures are two builds of testdata/bsdiff/v1 and v2 programs:
+// CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -trimpath -ldflags="-s -w -buildid="
+func TestBSDiffBinaries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping binary corpus in short mode")
+	}
+
+	a, _ := os.ReadFile("testdata/bsdiff/v1.bin")
+	b, _ := os.ReadFile("testdata/bsdiff/v2.bin")
+	if len(a) == 0 || len(b) == 0 {
+		t.Fatal("Expected binary fixtures in testdata/bsdiff")
+	}
+
+	delta := bsdiffDelta(a, b, WithCompression(CompressDeflate))
+	rolling := New(0, WithFileSize(int64(len(a))), WithCompression(CompressDeflate))
+	sig := rolling.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	blocks := rolling.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+		t.Fatalf("Expected patch without errors, got %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), b) {
+		t.Fatalf("Expected patched binary equal to target")
+	}
+
+	size, rollingSize := payloadSize(delta), payloadSize(blocks)
+	t.Logf("bsdiff payload %d bytes, rolling payload %d bytes, target %d bytes", size, rollingSize, len(b))
+	if size >= rollingSize {
+		t.Errorf("Expected bsdiff payload %d smaller than rolling payload %d", size, rollingSize)
+	}
+}
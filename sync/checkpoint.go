@@ -0,0 +1,170 @@
+package sync
+
+import (
+	"crypto/sha1"
+	"encoding"
+	"hash"
+)
+
+// Checkpoint mark a position in delta stream where an interrupted patch could be resumed
+type Checkpoint struct {
+	Op     int    // Index of next operation to apply
+	Offset int64  // Output bytes written before operation
+	Hash   []byte // Running strong hash state at offset
+}
+
+// Collect ordered operations for a delta stream computing
+// target checksum and checkpoints while operations get added
+type streamer struct {
+	interval int        // Output bytes between checkpoints
+	hash     hash.Hash  // Running target checksum
+	written  int64      // Output bytes written by operations
+	last     int64      // Output offset for last checkpoint
+	codec    Codec      // Literal compression codec, nil if disabled
+	dict     dictionary // Matched blocks since last checkpoint
+	stream   Stream
+}
+
+func newStreamer(interval int, compression Compression) *streamer {
+	codec, ok := codecFor(compression)
+	if !ok {
+		compression = CompressNone
+	}
+
+	return &streamer{
+		interval: interval,
+		hash:     sha1.New(),
+		codec:    codec,
+		stream:   Stream{Compression: compression},
+	}
+}
+
+// Add literal operation split in interval sized chunks
+func (s *streamer) literal(lit []byte) {
+	for len(lit) > 0 {
+		size := len(lit)
+		if s.interval > 0 && size > s.interval {
+			size = s.interval
+		}
+
+		s.add(s.compress(lit[:size]), lit[:size])
+		lit = lit[size:]
+	}
+}
+
+// Return literal operation, compressed only if it get smaller
+func (s *streamer) compress(lit []byte) Op {
+	op := Op{Type: OpLiteral, Lit: lit}
+	if s.codec == nil || len(lit) < minCompressSize {
+		return op
+	}
+
+	compressed, err := s.codec.Compress(lit, s.dict.Bytes())
+	if err != nil || len(compressed) >= len(lit) {
+		return op
+	}
+
+	op.Lit = compressed
+	op.Size = int64(len(lit))
+	return op
+}
+
+// Add copy operation for block, data is the block content.
+// Block get into dictionary before a checkpoint after it could reset it, as patcher do.
+func (s *streamer) copy(index, start, offset int64, data []byte) {
+	s.dict.Write(data)
+	s.add(Op{Type: OpCopy, Index: index, Start: start, Offset: offset}, data)
+}
+
+// Return last operation if it is a copy that could be extended,
+// operations before a checkpoint can't change
+func (s *streamer) lastCopy() (Op, bool) {
+	ops := s.stream.Ops
+	if len(ops) == 0 || ops[len(ops)-1].Type != OpCopy || s.last == s.written {
+		return Op{}, false
+	}
+
+	return ops[len(ops)-1], true
+}
+
+// Return basis position right after last copy operation,
+// or start of basis if there are no operations yet
+func (s *streamer) end() (int64, bool) {
+	ops := s.stream.Ops
+	if len(ops) == 0 {
+		return 0, true
+	}
+
+	last := ops[len(ops)-1]
+	return last.Offset, last.Type == OpCopy
+}
+
+// Add edit script operation over basis range, data is the output it produce
+func (s *streamer) edit(start, offset int64, edits []Edit, data []byte) {
+	s.add(Op{Type: OpEdit, Start: start, Offset: offset, Edits: edits}, data)
+}
+
+// Extend last copy operation with data following it in source
+func (s *streamer) extend(data []byte) {
+	s.hash.Write(data)
+	s.dict.Write(data)
+	s.written += int64(len(data))
+	s.stream.Ops[len(s.stream.Ops)-1].Offset += int64(len(data))
+}
+
+// Add zero run operation, merged into previous zero run unless a checkpoint is between them
+func (s *streamer) zero(size int64) {
+	last := len(s.stream.Ops) - 1
+	merge := last >= 0 && s.stream.Ops[last].Type == OpZero && s.last != s.written
+	zeros(s.hash, size)
+	s.written += size
+	if merge {
+		s.stream.Ops[last].Size += size
+	} else {
+		s.stream.Ops = append(s.stream.Ops, Op{Type: OpZero, Size: size})
+	}
+
+	s.mark()
+}
+
+func (s *streamer) add(op Op, data []byte) {
+	s.hash.Write(data)
+	s.written += int64(len(data))
+	s.stream.Ops = append(s.stream.Ops, op)
+	s.mark()
+}
+
+// Add checkpoint after last operation if interval is reached
+func (s *streamer) mark() {
+	if s.interval > 0 && s.written-s.last >= int64(s.interval) {
+		s.last = s.written
+		s.dict.Reset() // Resumed patch start with empty dictionary
+		s.stream.Checkpoints = append(s.stream.Checkpoints, Checkpoint{
+			Op:     len(s.stream.Ops),
+			Offset: s.written,
+			Hash:   state(s.hash),
+		})
+	}
+}
+
+func (s *streamer) done() Stream {
+	s.stream.Checksum = sum(s.hash)
+	return s.stream
+}
+
+// Return serialized running hash state
+func state(h hash.Hash) []byte {
+	state, _ := h.(encoding.BinaryMarshaler).MarshalBinary()
+	return state
+}
+
+// Restore running hash from serialized state, nil state return a new hash
+func restore(state []byte) (hash.Hash, error) {
+	h := sha1.New()
+	if state == nil {
+		return h, nil
+	}
+
+	err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state)
+	return h, err
+}
@@ -0,0 +1,191 @@
+package sync
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrCompressed is returned composing deltas with compressed literal or add data.
+// Compression dictionary is built from copied source bytes, not known without
+// source, so they can't be decompressed; compute deltas without compression instead.
+var ErrCompressed = errors.New("compressed deltas can't be composed")
+
+// Return primitive operations for op: edit scripts are split in copy and
+// literal operations, anything else is returned as is.
+func primitives(op Op) []Op {
+	if op.Type != OpEdit {
+		return []Op{op}
+	}
+
+	var ops []Op
+	cursor := op.Start
+	for _, edit := range op.Edits {
+		if edit.Keep > 0 {
+			ops = append(ops, Op{Type: OpCopy, Index: op.Index, Start: cursor, Offset: cursor + int64(edit.Keep)})
+		}
+
+		if len(edit.Insert) > 0 {
+			ops = append(ops, Op{Type: OpLiteral, Lit: edit.Insert})
+		}
+
+		cursor += int64(edit.Keep + edit.Delete)
+	}
+
+	return ops
+}
+
+// Return primitive operation output range [from, to)
+func slice(op Op, from, to int64) Op {
+	switch op.Type {
+	case OpLiteral:
+		return Op{Type: OpLiteral, Lit: op.Lit[from:to]}
+	case OpAdd:
+		return Op{Type: OpAdd, Start: op.Start + from, Offset: op.Start + to, Lit: op.Lit[from:to]}
+	case OpZero:
+		return Op{Type: OpZero, Size: to - from}
+	}
+
+	return Op{Type: OpCopy, Index: op.Index, Start: op.Start + from, Offset: op.Start + to}
+}
+
+// Intermediate version described by delta operations
+type layout struct {
+	ops       []Op    // Primitive operations
+	positions []int64 // Output position for each operation
+	size      int64   // Output size
+}
+
+func newLayout(delta Stream) (layout, error) {
+	var l layout
+	for _, op := range delta.Ops {
+		if op.Size > 0 && op.Type != OpZero {
+			return l, ErrCompressed
+		}
+
+		for _, primitive := range primitives(op) {
+			l.ops = append(l.ops, primitive)
+			l.positions = append(l.positions, l.size)
+			l.size += primitive.Len()
+		}
+	}
+
+	return l, nil
+}
+
+// Return primitive operations producing intermediate range [start, offset)
+func (l layout) resolve(start, offset int64) ([]Op, error) {
+	if start < 0 || offset > l.size {
+		return nil, errors.New("range out of intermediate version bounds")
+	}
+
+	var ops []Op
+	// First operation containing start
+	i := sort.Search(len(l.positions), func(i int) bool { return l.positions[i] > start }) - 1
+	for ; start < offset && i < len(l.ops); i++ {
+		end := l.positions[i] + l.ops[i].Len()
+		if end > offset {
+			end = offset
+		}
+
+		ops = append(ops, slice(l.ops[i], start-l.positions[i], end-l.positions[i]))
+		start = end
+	}
+
+	return ops, nil
+}
+
+// Compose deltas source -> intermediate and intermediate -> target into a single
+// delta source -> target, without rebuilding the intermediate version. Copies in
+// second delta are resolved to copies of source where first delta copied them.
+// Composed delta has no checkpoints. Deltas must not be compressed, it fails
+// with ErrCompressed as soon as a compressed operation is found.
+func Compose(first, second Stream) (Stream, error) {
+	intermediate, err := newLayout(first)
+	if err != nil {
+		return Stream{}, err
+	}
+
+	composed := Stream{Checksum: second.Checksum}
+	for _, op := range second.Ops {
+		if op.Size > 0 && op.Type != OpZero {
+			return Stream{}, ErrCompressed
+		}
+
+		for _, primitive := range primitives(op) {
+			if primitive.Type == OpLiteral || primitive.Type == OpZero {
+				composed.Ops = merge(composed.Ops, primitive)
+				continue
+			}
+
+			resolved, err := intermediate.resolve(primitive.Start, primitive.Offset)
+			if err != nil {
+				return Stream{}, err
+			}
+
+			var position int64
+			for _, r := range resolved {
+				size := r.Len()
+				if primitive.Type == OpAdd {
+					r = addDiff(r, primitive.Lit[position:position+size])
+				}
+
+				composed.Ops = merge(composed.Ops, r)
+				position += size
+			}
+		}
+	}
+
+	return composed, nil
+}
+
+// Add bytewise difference to resolved operation
+func addDiff(op Op, diff []byte) Op {
+	data := make([]byte, len(diff))
+	switch op.Type {
+	case OpLiteral:
+		for i := range data {
+			data[i] = op.Lit[i] + diff[i]
+		}
+
+		return Op{Type: OpLiteral, Lit: data}
+	case OpZero:
+		copy(data, diff)
+		return Op{Type: OpLiteral, Lit: data}
+	case OpAdd:
+		for i := range data {
+			data[i] = op.Lit[i] + diff[i]
+		}
+	default:
+		copy(data, diff)
+	}
+
+	return Op{Type: OpAdd, Start: op.Start, Offset: op.Offset, Lit: data}
+}
+
+// Append operation merging it with last one when contiguous
+func merge(ops []Op, op Op) []Op {
+	if op.Len() == 0 {
+		return ops
+	}
+
+	if len(ops) == 0 {
+		return append(ops, op)
+	}
+
+	last := &ops[len(ops)-1]
+	switch {
+	case last.Type == OpLiteral && op.Type == OpLiteral:
+		last.Lit = append(append([]byte{}, last.Lit...), op.Lit...)
+	case last.Type == OpZero && op.Type == OpZero:
+		last.Size += op.Size
+	case last.Type == OpCopy && op.Type == OpCopy && last.Offset == op.Start:
+		last.Offset = op.Offset
+	case last.Type == OpAdd && op.Type == OpAdd && last.Offset == op.Start:
+		last.Lit = append(append([]byte{}, last.Lit...), op.Lit...)
+		last.Offset = op.Offset
+	default:
+		return append(ops, op)
+	}
+
+	return ops
+}
@@ -0,0 +1,114 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// Return data with random insertions, removals and replacements
+func mutate(random *rand.Rand, data []byte) []byte {
+	out := append([]byte{}, data...)
+	for i := 0; i < 1+random.Intn(4); i++ {
+		at := random.Intn(len(out) + 1)
+		size := random.Intn(12)
+		chunk := make([]byte, size)
+		random.Read(chunk)
+
+		switch random.Intn(3) {
+		case 0: // insert
+			out = append(out[:at], append(chunk, out[at:]...)...)
+		case 1: // remove
+			end := at + size
+			if end > len(out) {
+				end = len(out)
+			}
+
+			out = append(out[:at], out[end:]...)
+		default: // replace
+			end := at + size
+			if end > len(out) {
+				end = len(out)
+			}
+
+			copy(out[at:end], chunk)
+		}
+	}
+
+	return out
+}
+
+func TestCompose(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	base := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 3)
+
+	modes := []func(basis []byte) []Option{
+		func([]byte) []Option { return nil },
+		func(basis []byte) []Option { return []Option{WithBasis(bytes.NewReader(basis))} },
+		func(basis []byte) []Option {
+			return []Option{WithBasis(bytes.NewReader(basis)), WithEditScripts()}
+		},
+		func(basis []byte) []Option { return []Option{WithBasis(bytes.NewReader(basis)), WithBSDiff()} },
+	}
+
+	diff := func(a, b []byte, options []Option) Stream {
+		sync := New(1<<4, options...)
+		sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+		return sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+	}
+
+	for i := 0; i < 200; i++ {
+		v1 := mutate(random, base)
+		v2 := mutate(random, v1)
+		v3 := mutate(random, v2)
+
+		first := modes[random.Intn(len(modes))]
+		second := modes[random.Intn(len(modes))]
+		d1 := diff(v1, v2, first(v1))
+		d2 := diff(v2, v3, second(v2))
+
+		composed, err := Compose(d1, d2)
+		if err != nil {
+			t.Fatalf("Expected composed delta without errors, got %v", err)
+		}
+
+		// Same result as sequential patching
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(v1), composed, &out); err != nil {
+			t.Fatalf("Expected composed patch without errors, got %v", err)
+		}
+
+		if !bytes.Equal(out.Bytes(), v3) {
+			t.Fatalf("Expected composed patch output equal to sequential patching")
+		}
+	}
+}
+
+func TestComposeKeepCopies(t *testing.T) {
+	v1 := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	v2 := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash")
+	v3 := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash!!")
+
+	d1, d2 := extendedDelta(v1, v2), extendedDelta(v2, v3)
+	composed, _ := Compose(d1, d2)
+
+	// Unchanged blocks are copied from v1, only new bytes are literal
+	if string(literals(composed)) != "added!!" {
+		t.Errorf("Expected only new bytes as literal, got %q", literals(composed))
+	}
+}
+
+func TestComposeCompressed(t *testing.T) {
+	v1 := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	d1 := Stream{Ops: []Op{{Type: OpLiteral, Lit: []byte("compressed"), Size: 20}}}
+
+	if _, err := Compose(d1, CalculateStream(v1, v1)); !errors.Is(err, ErrCompressed) {
+		t.Errorf("Expected ErrCompressed composing compressed first delta, got %v", err)
+	}
+
+	if _, err := Compose(CalculateStream(v1, v1), d1); !errors.Is(err, ErrCompressed) {
+		t.Errorf("Expected ErrCompressed composing compressed second delta, got %v", err)
+	}
+}
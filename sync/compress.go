@@ -0,0 +1,120 @@
+package sync
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"sync"
+)
+
+// Max shared dictionary size, deflate window size
+const DictSize = 1 << 15 // 32 KiB
+
+// Smaller literals are not worth compression overhead
+const minCompressSize = 1 << 6 // 64 bytes
+
+// Compression algorithm for literal data in delta
+type Compression uint8
+
+const (
+	CompressNone    Compression = iota // Raw literals
+	CompressDeflate                    // DEFLATE from standard library
+	CompressZstd                       // Zstandard
+)
+
+// Codec compress and decompress literal data using a dictionary shared by both sides
+type Codec interface {
+	Compress(data, dict []byte) ([]byte, error)
+	Decompress(data, dict []byte) ([]byte, error)
+}
+
+// SessionCodec is a Codec making an instance for each delta stream or patch,
+// so state like encoders is reused between its literals. Instances are not
+// used concurrently.
+type SessionCodec interface {
+	Codec
+	Session() Codec
+}
+
+// Available codecs for compression algorithms
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[Compression]Codec{
+		CompressDeflate: deflate{},
+		CompressZstd:    zstdCodec{},
+	}
+)
+
+// Register codec implementation for compression algorithm,
+// replacing bundled one if any. Safe for concurrent use.
+func RegisterCodec(c Compression, codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c] = codec
+}
+
+// Return codec for compression algorithm, a session of it if supported
+func codecFor(c Compression) (Codec, bool) {
+	codecsMu.RLock()
+	codec, ok := codecs[c]
+	codecsMu.RUnlock()
+	if session, isSession := codec.(SessionCodec); isSession {
+		return session.Session(), true
+	}
+
+	return codec, ok
+}
+
+// DEFLATE codec with preset dictionary
+type deflate struct{}
+
+func (deflate) Compress(data, dict []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriterDict(&buf, flate.BestCompression, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (deflate) Decompress(data, dict []byte) ([]byte, error) {
+	r := flate.NewReaderDict(bytes.NewReader(data), dict)
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// Dictionary keep the last matched block bytes, known by both sides,
+// to prime literal compression
+type dictionary struct {
+	buf []byte
+}
+
+func (d *dictionary) Write(p []byte) (int, error) {
+	d.buf = append(d.buf, p...)
+	// Keep only the last DictSize bytes, copy to release old memory
+	if len(d.buf) > 2*DictSize {
+		d.buf = append([]byte{}, d.buf[len(d.buf)-DictSize:]...)
+	}
+
+	return len(p), nil
+}
+
+// Return the last DictSize bytes written
+func (d *dictionary) Bytes() []byte {
+	if len(d.buf) > DictSize {
+		return d.buf[len(d.buf)-DictSize:]
+	}
+
+	return d.buf
+}
+
+func (d *dictionary) Reset() { d.buf = nil }
@@ -0,0 +1,148 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// Return literal bytes stored in delta stream
+func literalSize(delta Stream) int {
+	size := 0
+	for _, op := range delta.Ops {
+		if op.Type == OpLiteral {
+			size += len(op.Lit)
+		}
+	}
+
+	return size
+}
+
+func compressedDelta(a, b []byte, c Compression) Stream {
+	sync := New(1<<4, WithCompression(c))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	return sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+}
+
+// Compressed delta must be smaller than raw one and patch back to target
+func testCompression(t *testing.T, c Compression) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	// New repetitive paragraph not found in source
+	b := append(append([]byte{}, a...), bytes.Repeat([]byte(" the quick brown fox jumps over the lazy dog"), 4)...)
+
+	raw := compressedDelta(a, b, CompressNone)
+	delta := compressedDelta(a, b, c)
+	if delta.Compression != c {
+		t.Fatalf("Expected compression %d recorded in delta", c)
+	}
+
+	if literalSize(delta) >= literalSize(raw) {
+		t.Errorf("Expected compressed literals %d smaller than raw %d", literalSize(delta), literalSize(raw))
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+		t.Fatalf("Expected patch without errors, got %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), b) {
+		t.Errorf("Expected patched output equal to target")
+	}
+}
+
+func TestCompressDeflate(t *testing.T) {
+	testCompression(t, CompressDeflate)
+}
+
+func TestCompressZstd(t *testing.T) {
+	testCompression(t, CompressZstd)
+}
+
+func TestZstdDictionary(t *testing.T) {
+	dict := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 20)
+	data := []byte("the quick brown fox jumps over the lazy dog again and again")
+
+	plain, _ := zstdCodec{}.Compress(data, nil)
+	primed, err := zstdCodec{}.Compress(data, dict)
+	if err != nil || len(primed) >= len(plain) {
+		t.Fatalf("Expected dictionary to shrink compressed data, got %d and %d bytes, %v", len(primed), len(plain), err)
+	}
+
+	if out, err := (zstdCodec{}).Decompress(primed, dict); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("Expected data decompressed with dictionary, got %v", err)
+	}
+}
+
+func TestZstdSession(t *testing.T) {
+	dict := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 20)
+	data := []byte("the quick brown fox jumps over the lazy dog again and again")
+	session := zstdCodec{}.Session().(*zstdSession)
+
+	first, _ := session.Compress(data, dict)
+	encoder := session.encoder
+	second, _ := session.Compress(data, append([]byte{}, dict...))
+	if session.encoder != encoder || !bytes.Equal(first, second) {
+		t.Errorf("Expected encoder reused for unchanged dictionary")
+	}
+
+	session.Compress(data, dict[1:])
+	if session.encoder == encoder {
+		t.Errorf("Expected encoder rebuilt for changed dictionary")
+	}
+
+	if out, err := session.Decompress(first, dict); err != nil || !bytes.Equal(out, data) {
+		t.Errorf("Expected data decompressed by session, got %v", err)
+	}
+}
+
+func TestCompressUnregistered(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	b := append(append([]byte{}, a...), a...)
+
+	// No codec registered, literals are kept raw
+	unknown := Compression(0xff)
+	delta := compressedDelta(a, b, unknown)
+	if delta.Compression != CompressNone {
+		t.Errorf("Expected no compression recorded without registered codec")
+	}
+
+	_, err := NewPatcher(bytes.NewReader(a), new(bytes.Buffer), Checkpoint{}, unknown)
+	if err == nil {
+		t.Errorf("Expected error creating patcher without registered codec")
+	}
+}
+
+func TestDictionary(t *testing.T) {
+	var dict dictionary
+	dict.Write(bytes.Repeat([]byte("a"), DictSize))
+	dict.Write(bytes.Repeat([]byte("b"), DictSize+1))
+
+	window := dict.Bytes()
+	if len(window) != DictSize || window[0] != 'b' {
+		t.Errorf("Expected dictionary to keep only last %d bytes", DictSize)
+	}
+}
+
+func TestCompressCheckpoints(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	words := []string{"rolling ", "hash ", "block ", "delta ", "patch ", "signature "}
+	var basis []byte
+	for len(basis) < 1<<14 {
+		basis = append(basis, words[random.Intn(len(words))]...)
+	}
+
+	// Dictionary must restart at the same point on both sides for every checkpoint
+	for i := 0; i < 50; i++ {
+		target := mutate(random, basis)
+		compression := []Compression{CompressDeflate, CompressZstd}[i%2]
+		sync := New(1<<6, WithCompression(compression), WithCheckpoint(1<<8+random.Intn(1<<10)))
+		sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+		delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(basis), delta, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+			t.Fatalf("Expected compressed delta with checkpoints patched, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package sync
+
+import "math/bits"
+
+// Max literal and basis gap size to compute edit scripts, bound Myers cost
+const maxEditSize = 1 << 12 // 4 KiB
+
+// Max edit distance explored, scripts with more changes are not worth it
+const maxEditDepth = 1 << 9
+
+// Edit is a step of a byte level edit script applied over a basis range:
+// copy Keep bytes from basis, skip Delete basis bytes and write Insert bytes.
+type Edit struct {
+	Keep   int
+	Delete int
+	Insert []byte
+}
+
+// Edit script line types
+const (
+	equal = iota
+	insert
+	remove
+)
+
+// Calculate shortest edit script to turn basis into target using Myers diff algorithm.
+// Return nil if edit distance exceed maxEditDepth.
+// See also: http://www.xmailserver.org/diff2.pdf
+func diffBytes(basis, target []byte) []Edit {
+	n, m := len(basis), len(target)
+	max := n + m
+	if max > maxEditDepth {
+		max = maxEditDepth
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	// Find shortest path length keeping furthest reaching x for each diagonal
+	for d := 0; d <= max; d++ {
+		// Keep diagonals -d..d for backtracking
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down, insert
+			} else {
+				x = v[offset+k-1] + 1 // move right, delete
+			}
+
+			y := x - k
+			for x < n && y < m && basis[x] == target[y] {
+				x, y = x+1, y+1
+			}
+
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return script(backtrack(trace, basis, target, d), target)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Walk back the trace returning edit lines in order
+func backtrack(trace [][]int, basis, target []byte, depth int) []int {
+	var lines []int
+	x, y := len(basis), len(target)
+
+	for d := depth; d > 0; d-- {
+		v := trace[d] // diagonal k is stored at k+d
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			lines = append(lines, equal)
+			x, y = x-1, y-1
+		}
+
+		if x == prevX {
+			lines = append(lines, insert)
+		} else {
+			lines = append(lines, remove)
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for ; x > 0 && y > 0; x, y = x-1, y-1 {
+		lines = append(lines, equal)
+	}
+
+	// Reverse to get lines in order
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return lines
+}
+
+// Group edit lines into Keep/Delete/Insert steps
+func script(lines []int, target []byte) []Edit {
+	var edits []Edit
+	var current Edit
+	position := 0 // target position
+
+	for _, line := range lines {
+		switch line {
+		case equal:
+			// Keep after delete or insert start a new step
+			if current.Delete > 0 || len(current.Insert) > 0 {
+				edits = append(edits, current)
+				current = Edit{}
+			}
+
+			current.Keep++
+			position++
+		case remove:
+			if len(current.Insert) > 0 {
+				edits = append(edits, current)
+				current = Edit{}
+			}
+
+			current.Delete++
+		case insert:
+			current.Insert = append(current.Insert, target[position])
+			position++
+		}
+	}
+
+	if current.Keep > 0 || current.Delete > 0 || len(current.Insert) > 0 {
+		edits = append(edits, current)
+	}
+
+	return edits
+}
+
+// Return estimated encoded size of edit operation over basis range, gob alike:
+// every non zero field cost a tag byte plus its value
+func editSize(start, offset int64, edits []Edit) int {
+	size := 3 + intSize(start) + intSize(offset) + intSize(int64(len(edits)))
+	for _, edit := range edits {
+		size++ // Struct end
+		if edit.Keep > 0 {
+			size += 1 + intSize(int64(edit.Keep))
+		}
+
+		if edit.Delete > 0 {
+			size += 1 + intSize(int64(edit.Delete))
+		}
+
+		if len(edit.Insert) > 0 {
+			size += 1 + intSize(int64(len(edit.Insert))) + len(edit.Insert)
+		}
+	}
+
+	return size
+}
+
+// Return estimated encoded size of literal operation data
+func literalOpSize(lit []byte) int {
+	return 1 + intSize(int64(len(lit))) + len(lit)
+}
+
+// Return encoded size of non negative integer, gob alike
+func intSize(v int64) int {
+	u := uint64(v) << 1
+	if u < 0x80 {
+		return 1
+	}
+
+	return 1 + (bits.Len64(u)+7)/8
+}
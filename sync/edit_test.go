@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// Return literal bytes plus estimated edit operations size stored in delta stream
+func payloadSize(delta Stream) int {
+	size := 0
+	for _, op := range delta.Ops {
+		size += len(op.Lit)
+		if op.Type == OpEdit {
+			size += editSize(op.Start, op.Offset, op.Edits)
+		}
+	}
+
+	return size
+}
+
+func TestDiffBytes(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomBytes := func() []byte {
+		b := make([]byte, random.Intn(64))
+		for i := range b {
+			b[i] = "abc"[random.Intn(3)]
+		}
+
+		return b
+	}
+
+	for i := 0; i < 1000; i++ {
+		basis, target := randomBytes(), randomBytes()
+		edits := diffBytes(basis, target)
+
+		var out []byte
+		cursor := 0
+		for _, edit := range edits {
+			out = append(out, basis[cursor:cursor+edit.Keep]...)
+			out = append(out, edit.Insert...)
+			cursor += edit.Keep + edit.Delete
+		}
+
+		if !bytes.Equal(out, target) {
+			t.Fatalf("Expected edit script to turn %q into %q, got %q", basis, target, out)
+		}
+	}
+}
+
+func TestEditScripts(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	targets := []struct {
+		data    []byte
+		smaller bool // Smaller than extended matches, not only than block literals
+	}{
+		// Typos are already trimmed to the changed bytes by match extension
+		{[]byte("i here guys how are you doing this is a mall test chunk split and rolling hash"), false},
+		{[]byte("i am here guys how are you doing thus is a smell test for chunk split and rolling hash"), false},
+		// Changes scattered over a replaced range
+		{[]byte("i am her guys how are you doin this is a smal test for chunk split and rolling hash"), true},
+		{[]byte("i am hére guys how are yöu doing this is a smäll test for chunk split and rolling hash"), true},
+		{[]byte("i am here guys   how are you doing    test for chunk split and rolling hash"), true},
+	}
+
+	for _, target := range targets {
+		b := target.data
+		sync := New(1<<4, WithBasis(bytes.NewReader(a)), WithEditScripts())
+		sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+		delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+		// Compare encoded size with block literals and extended matches only
+		raw, extended := CalculateStream(a, b), extendedDelta(a, b)
+		size, extendedSize := encodedSize(t, delta), encodedSize(t, extended)
+		if size >= encodedSize(t, raw) || size > extendedSize || target.smaller && size == extendedSize {
+			t.Errorf("Expected edit scripts delta %d bytes smaller than literals %d/%d for %q",
+				size, encodedSize(t, raw), extendedSize, b)
+		}
+
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+			t.Fatalf("Expected patch without errors, got %v", err)
+		}
+
+		if !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("Expected patched output %q equal to target", out.Bytes())
+		}
+
+		if target.smaller && InPlace(delta) {
+			t.Errorf("Expected delta with edit scripts not applied in place")
+		}
+	}
+}
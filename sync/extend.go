@@ -0,0 +1,50 @@
+package sync
+
+import "io"
+
+// Return how many trailing literal bytes match basis right before start,
+// at most limit bytes. Those bytes could be copied instead of sent as literal.
+func extendBackward(basis io.ReaderAt, lit []byte, start int64, limit int) int {
+	size := limit
+	if len(lit) < size {
+		size = len(lit)
+	}
+
+	if start < int64(size) {
+		size = int(start)
+	}
+
+	if size <= 0 {
+		return 0
+	}
+
+	before := make([]byte, size)
+	read, _ := basis.ReadAt(before, start-int64(size))
+	before = before[:read]
+
+	matched := 0
+	for matched < len(before) && lit[len(lit)-1-matched] == before[len(before)-1-matched] {
+		matched++
+	}
+
+	return matched
+}
+
+// Return how many leading literal bytes match basis right after offset,
+// at most limit bytes. Those bytes could be copied instead of sent as literal.
+func extendForward(basis io.ReaderAt, lit []byte, offset int64, limit int) int {
+	size := limit
+	if len(lit) < size {
+		size = len(lit)
+	}
+
+	after := make([]byte, size)
+	read, _ := basis.ReadAt(after, offset)
+
+	matched := 0
+	for matched < read && lit[matched] == after[matched] {
+		matched++
+	}
+
+	return matched
+}
@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func extendedDelta(a, b []byte) Stream {
+	sync := New(1<<4, WithBasis(bytes.NewReader(a)))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	return sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+}
+
+// Return literal data in delta stream
+func literals(delta Stream) []byte {
+	var lit []byte
+	for _, op := range delta.Ops {
+		if op.Type == OpLiteral {
+			lit = append(lit, op.Lit...)
+		}
+	}
+
+	return lit
+}
+
+func TestExtendMatches(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	expected := map[string]string{
+		"i am here guys how are you doing this is a smell test for chunk split and rolling hash":      "e",     // typo
+		"i am here guys how are you doingadded this is a small test for chunk split and rolling hash": "added", // addition
+		"i am here guys how are you doing this is a smal test for chunk split and rolling hash":       "",      // removal
+		"i am here guys how are you doing this is a small test for chunk split and rolling hash!":     "!",     // trailing
+	}
+
+	for target, lit := range expected {
+		b := []byte(target)
+		delta := extendedDelta(a, b)
+		if string(literals(delta)) != lit {
+			t.Errorf("Expected literal %q for %q, got %q", lit, target, literals(delta))
+		}
+
+		// Without basis the whole changed block is literal
+		if lit != "" && literalSize(CalculateStream(a, b)) <= len(lit) {
+			t.Errorf("Expected extended literal smaller than block literal for %q", target)
+		}
+
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+			t.Fatalf("Expected patch without errors, got %v", err)
+		}
+
+		if !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("Expected patched output %q equal to target", out.Bytes())
+		}
+	}
+}
+
+func TestExtendDeltaTable(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	b := []byte("i am here guys how are you doing this is a smell test for chunk split and rolling hash")
+
+	sync := New(1<<4, WithBasis(bytes.NewReader(a)))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := sync.Delta(sig, bufio.NewReader(bytes.NewReader(b)))
+	plain := New(1<<4).Delta(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	// Matches are not extended, block literals keep every target byte
+	if !reflect.DeepEqual(delta, plain) {
+		t.Errorf("Expected delta table unchanged by basis, got %v", delta)
+	}
+
+	if string(delta[3].Lit) != " this is a smell" {
+		t.Errorf("Expected whole changed block as literal, got %q", delta[3].Lit)
+	}
+}
@@ -0,0 +1,217 @@
+package sync
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+)
+
+// Node position in Merkle tree, level 0 are blocks
+type Node struct {
+	Level int
+	Index int
+}
+
+// Head describe a signature without its tables, first thing exchanged
+type Head struct {
+	BlockSize int
+	Seed      []byte
+	Checksum  string
+	Leaves    int
+}
+
+// Remote give access to a Merkle tree held by the other side, eg. over a connection.
+// Tree implement it for local trees.
+type Remote interface {
+	Head() (Head, error)
+	Hashes(nodes []Node) ([]string, error)
+	Tables(leaves []int) ([]Table, error)
+}
+
+// Tree is a Merkle tree over signature blocks, so two sides can compare roots
+// and descend only into differing subtrees, exchanging tables only for changed blocks.
+// Node hashes don't depend on tree height, missing nodes are empty.
+type Tree struct {
+	sig    Signature
+	levels [][]string // Level 0 hash block tables, last level is root
+}
+
+// Build Merkle tree over signature tables
+func NewTree(sig Signature) *Tree {
+	leaves := make([]string, len(sig.Tables))
+	for i, table := range sig.Tables {
+		weak := make([]byte, 4)
+		binary.BigEndian.PutUint32(weak, table.Weak)
+		leaves[i] = node(string(weak), table.Strong)
+	}
+
+	levels := [][]string{leaves}
+	for len(levels[len(levels)-1]) > 1 {
+		below := levels[len(levels)-1]
+		level := make([]string, (len(below)+1)/2)
+		for i := range level {
+			level[i] = parent(below, i)
+		}
+
+		levels = append(levels, level)
+	}
+
+	return &Tree{sig: sig, levels: levels}
+}
+
+// Return hash for node data
+func node(left, right string) string {
+	h := sha1.New()
+	h.Write([]byte(left))
+	h.Write([]byte(right))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Return hash of node i parent of below level nodes 2i and 2i+1
+func parent(below []string, i int) string {
+	left, right := "", ""
+	if 2*i < len(below) {
+		left = below[2*i]
+	}
+
+	if 2*i+1 < len(below) {
+		right = below[2*i+1]
+	}
+
+	if left == "" && right == "" {
+		return ""
+	}
+
+	return node(left, right)
+}
+
+// Return tree height needed to cover leaves
+func height(leaves int) int {
+	h := 0
+	for 1<<h < leaves {
+		h++
+	}
+
+	return h
+}
+
+// Return node hash, empty if node does not cover any block
+func (t *Tree) hash(n Node) string {
+	if n.Level < len(t.levels) {
+		if n.Index < len(t.levels[n.Level]) {
+			return t.levels[n.Level][n.Index]
+		}
+
+		return ""
+	}
+
+	// Above root only the first node cover blocks
+	if n.Index > 0 {
+		return ""
+	}
+
+	return parent([]string{t.hash(Node{n.Level - 1, 0})}, 0)
+}
+
+// Return tree root for height, same height must be used to compare trees
+func (t *Tree) Root(height int) string {
+	return t.hash(Node{height, 0})
+}
+
+// Return signature head
+func (t *Tree) Head() (Head, error) {
+	return Head{
+		BlockSize: t.sig.BlockSize,
+		Seed:      t.sig.Seed,
+		Checksum:  t.sig.Checksum,
+		Leaves:    len(t.sig.Tables),
+	}, nil
+}
+
+// Return nodes hashes
+func (t *Tree) Hashes(nodes []Node) ([]string, error) {
+	hashes := make([]string, len(nodes))
+	for i, n := range nodes {
+		hashes[i] = t.hash(n)
+	}
+
+	return hashes, nil
+}
+
+// Return blocks tables
+func (t *Tree) Tables(leaves []int) ([]Table, error) {
+	tables := make([]Table, len(leaves))
+	for i, leaf := range leaves {
+		if leaf < 0 || leaf >= len(t.sig.Tables) {
+			return nil, errors.New("block out of signature")
+		}
+
+		tables[i] = t.sig.Tables[leaf]
+	}
+
+	return tables, nil
+}
+
+// Return remote signature fetching only tables of blocks differing from local tree,
+// plus the fetched blocks. Local tree must be built with remote block size and seed,
+// see Head. Blocks equal in both trees take local tables.
+func (t *Tree) Fetch(remote Remote) (Signature, []int, error) {
+	head, err := remote.Head()
+	if err != nil {
+		return Signature{}, nil, err
+	}
+
+	if head.BlockSize != t.sig.BlockSize || string(head.Seed) != string(t.sig.Seed) {
+		return Signature{}, nil, errors.New("trees built with different block size or seed")
+	}
+
+	top := height(head.Leaves)
+	if local := height(len(t.sig.Tables)); local > top {
+		top = local
+	}
+
+	// Descend level by level into differing nodes covering remote blocks
+	var leaves []int
+	frontier := []Node{{top, 0}}
+	for len(frontier) > 0 {
+		hashes, err := remote.Hashes(frontier)
+		if err != nil {
+			return Signature{}, nil, err
+		}
+
+		var next []Node
+		for i, n := range frontier {
+			if hashes[i] == t.hash(n) {
+				continue
+			}
+
+			if n.Level == 0 {
+				leaves = append(leaves, n.Index)
+				continue
+			}
+
+			for _, child := range []int{2 * n.Index, 2*n.Index + 1} {
+				if child<<(n.Level-1) < head.Leaves {
+					next = append(next, Node{n.Level - 1, child})
+				}
+			}
+		}
+
+		frontier = next
+	}
+
+	tables, err := remote.Tables(leaves)
+	if err != nil {
+		return Signature{}, nil, err
+	}
+
+	sig := Signature{BlockSize: head.BlockSize, Seed: head.Seed, Checksum: head.Checksum}
+	sig.Tables = make([]Table, head.Leaves)
+	copy(sig.Tables, t.sig.Tables)
+	for i, leaf := range leaves {
+		sig.Tables[leaf] = tables[i]
+	}
+
+	return sig, leaves, nil
+}
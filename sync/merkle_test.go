@@ -0,0 +1,108 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Remote counting exchanged hashes and tables
+type countingRemote struct {
+	*Tree
+	rounds int
+	hashes int
+	tables int
+}
+
+func (c *countingRemote) Hashes(nodes []Node) ([]string, error) {
+	c.rounds++
+	c.hashes += len(nodes)
+	return c.Tree.Hashes(nodes)
+}
+
+func (c *countingRemote) Tables(leaves []int) ([]Table, error) {
+	c.tables += len(leaves)
+	return c.Tree.Tables(leaves)
+}
+
+func TestTreeRoot(t *testing.T) {
+	sync := New(4)
+	a := sync.BuildSigTable(bufio.NewReader(bytes.NewReader([]byte("i am here guys how are you doing"))))
+	b := sync.BuildSigTable(bufio.NewReader(bytes.NewReader([]byte("i am here guys how are you doing"))))
+	c := sync.BuildSigTable(bufio.NewReader(bytes.NewReader([]byte("i am HERE guys how are you doing"))))
+
+	if NewTree(a).Root(3) != NewTree(b).Root(3) {
+		t.Errorf("Expected equal roots for equal files")
+	}
+
+	if NewTree(a).Root(3) == NewTree(c).Root(3) {
+		t.Errorf("Expected different roots for edited files")
+	}
+
+	// Root above tree height still depends on blocks
+	if NewTree(a).Root(5) == NewTree(c).Root(5) || NewTree(a).Root(5) == "" {
+		t.Errorf("Expected different roots above tree height")
+	}
+}
+
+func TestTreeFetch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<20)
+	random.Read(basis)
+	blockSize := 1 << 10
+
+	sparse := append([]byte{}, basis...)
+	for _, at := range []int{1000, 300000, 700001} {
+		sparse[at] ^= 0xff
+	}
+
+	cases := []struct {
+		name    string
+		target  []byte
+		fetched int // Tables exchanged
+	}{
+		{"equal", basis, 0},
+		{"sparse", sparse, 3},
+		{"append", append(append([]byte{}, basis...), "appended"...), 0}, // Appended blocks are only local
+		{"truncate", basis[:len(basis)-5000], 5},
+		{"insert", append(append(append([]byte{}, basis[:len(basis)-3000]...), "inserted"...), basis[len(basis)-3000:]...), 3},
+	}
+
+	sync := New(blockSize)
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	for _, c := range cases {
+		remote := &countingRemote{Tree: NewTree(sig)}
+		local := NewTree(sync.BuildSigTable(bufio.NewReader(bytes.NewReader(c.target))))
+		fetched, leaves, err := local.Fetch(remote)
+		if err != nil || !reflect.DeepEqual(fetched, sig) {
+			t.Fatalf("Expected %s remote signature rebuilt, got %v", c.name, err)
+		}
+
+		if len(leaves) != c.fetched || remote.tables != c.fetched {
+			t.Errorf("Expected %s to fetch %d tables, got %d", c.name, c.fetched, remote.tables)
+		}
+
+		// One round per level, hashes only along differing paths
+		if remote.rounds > height(len(sig.Tables))+2 || remote.hashes > 2*(c.fetched+1)*(height(len(sig.Tables))+1) {
+			t.Errorf("Expected %s to exchange few hashes, got %d in %d rounds", c.name, remote.hashes, remote.rounds)
+		}
+
+		// Fetched signature is as good as the full one
+		delta := sync.Diff(fetched, bufio.NewReader(bytes.NewReader(c.target)))
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(basis), delta, &out); err != nil || !bytes.Equal(out.Bytes(), c.target) {
+			t.Errorf("Expected %s patched from fetched signature, got %v", c.name, err)
+		}
+	}
+}
+
+func TestTreeFetchMismatch(t *testing.T) {
+	data := []byte("i am here guys how are you doing")
+	remote := NewTree(New(4).BuildSigTable(bufio.NewReader(bytes.NewReader(data))))
+	local := NewTree(New(4, WithSeed([]byte("seed"))).BuildSigTable(bufio.NewReader(bytes.NewReader(data))))
+	if _, _, err := local.Fetch(remote); err == nil {
+		t.Errorf("Expected error on trees built with different seed")
+	}
+}
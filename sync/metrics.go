@@ -0,0 +1,52 @@
+package sync
+
+import "sync/atomic"
+
+// Metrics receive block matching events, eg. to export them to a monitoring system.
+// Methods could be called concurrently by Sync sharing the same Metrics.
+type Metrics interface {
+	WeakHit()       // Weak checksum found in signature, strong checksum computed
+	StrongMatch()   // Strong checksum confirmed weak hit
+	FalsePositive() // Strong checksum rejected weak hit
+}
+
+// Counters keep block matching events totals
+type Counters struct {
+	WeakHits       uint64
+	StrongMatches  uint64
+	FalsePositives uint64
+}
+
+// Return rate of weak hits rejected by strong checksum
+func (c Counters) FalsePositiveRate() float64 {
+	if c.WeakHits == 0 {
+		return 0
+	}
+
+	return float64(c.FalsePositives) / float64(c.WeakHits)
+}
+
+// Counters safe for concurrent updates
+type counters struct {
+	weakHits       uint64
+	strongMatches  uint64
+	falsePositives uint64
+}
+
+func (c *counters) WeakHit()       { atomic.AddUint64(&c.weakHits, 1) }
+func (c *counters) StrongMatch()   { atomic.AddUint64(&c.strongMatches, 1) }
+func (c *counters) FalsePositive() { atomic.AddUint64(&c.falsePositives, 1) }
+
+// Return counters snapshot
+func (c *counters) load() Counters {
+	return Counters{
+		WeakHits:       atomic.LoadUint64(&c.weakHits),
+		StrongMatches:  atomic.LoadUint64(&c.strongMatches),
+		FalsePositives: atomic.LoadUint64(&c.falsePositives),
+	}
+}
+
+// Return block matching totals for every delta calculated by Sync
+func (s *Sync) Counters() Counters {
+	return s.counters.load()
+}
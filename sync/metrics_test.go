@@ -0,0 +1,93 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Metrics collecting events in order
+type events []string
+
+func (e *events) WeakHit()       { *e = append(*e, "weak") }
+func (e *events) StrongMatch()   { *e = append(*e, "strong") }
+func (e *events) FalsePositive() { *e = append(*e, "false") }
+
+func TestCounters(t *testing.T) {
+	// "caac" = "bbbb" + (1, -1, -1, 1) keep adler32 sums, not SHA-1 ones
+	a := []byte("bbbbdddd")
+	b := []byte("caacdddd")
+
+	var received events
+	sync := New(4, WithMetrics(&received))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	expected := Counters{WeakHits: 2, StrongMatches: 1, FalsePositives: 1}
+	if sync.Counters() != expected {
+		t.Errorf("Expected counters %+v, got %+v", expected, sync.Counters())
+	}
+
+	if sync.Counters().FalsePositiveRate() != 0.5 {
+		t.Errorf("Expected false positive rate 0.5, got %f", sync.Counters().FalsePositiveRate())
+	}
+
+	if len(received) != 4 || received[0] != "weak" || received[1] != "false" || received[3] != "strong" {
+		t.Errorf("Expected metrics to receive every event in order, got %v", received)
+	}
+
+	// Counters keep totals for every delta
+	sync.Seek(sync.BuildIndexes(sig.Tables), weak(a[4:]), a[4:])
+	if sync.Counters().StrongMatches != 2 {
+		t.Errorf("Expected Seek counted as strong match, got %+v", sync.Counters())
+	}
+}
+
+// Report weak checksum false positives per window over unrelated random data
+func BenchmarkFalsePositives(b *testing.B) {
+	weakHashes := map[string][]Option{
+		"adler32":        nil,
+		"seeded-adler32": {WithSeed([]byte("session seed"))},
+	}
+
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<18)
+	target := make([]byte, 1<<18)
+	random.Read(basis)
+	random.Read(target)
+
+	for name, options := range weakHashes {
+		for _, blockSize := range []int{16, 64, 700} {
+			sync := New(blockSize, options...)
+			indexes := sync.BuildIndexes(sync.BuildSigTable(bufio.NewReader(bytes.NewReader(basis))).Tables)
+
+			// Weak checksum for every target window
+			var sums []uint32
+			rolling := sync.rolling()
+			for i, c := range target {
+				rolling = rolling.RollIn(c)
+				if i >= blockSize {
+					rolling = rolling.RollOut()
+				}
+
+				if rolling.Count() == blockSize {
+					sums = append(sums, rolling.Sum())
+				}
+			}
+
+			b.Run(fmt.Sprintf("%s/%d", name, blockSize), func(b *testing.B) {
+				before := sync.Counters()
+				for i := 0; i < b.N; i++ {
+					at := i % len(sums)
+					sync.Seek(indexes, sums[at], target[at:at+blockSize])
+				}
+
+				after := sync.Counters()
+				b.ReportMetric(float64(after.FalsePositives-before.FalsePositives)/float64(b.N), "fp/window")
+				b.ReportMetric(float64(after.WeakHits-before.WeakHits)/float64(b.N), "hits/window")
+			})
+		}
+	}
+}
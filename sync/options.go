@@ -0,0 +1,84 @@
+package sync
+
+import "io"
+
+// Default output bytes between delta checkpoints
+const CheckpointInterval = 1 << 20 // 1 MiB
+
+// Option customize Sync behavior
+type Option func(*Sync)
+
+// Set output bytes between checkpoints emitted in delta stream.
+// Literal operations are split to this size so checkpoints stay bounded.
+func WithCheckpoint(interval int) Option {
+	return func(s *Sync) {
+		s.checkpoint = interval
+	}
+}
+
+// Set compression algorithm for literal data in delta stream.
+// Compression is disabled if no codec is registered for algorithm.
+func WithCompression(c Compression) Option {
+	return func(s *Sync) {
+		s.compression = c
+	}
+}
+
+// Set size of the file to sign, used to pick block size with AutoBlockSize
+// when Sync is created with zero block size.
+func WithFileSize(size int64) Option {
+	return func(s *Sync) {
+		s.fileSize = size
+	}
+}
+
+// Set basis data to extend block matches byte by byte, so literals
+// shrink to the exact changed bytes. Delta side must have access to basis.
+func WithBasis(basis io.ReaderAt) Option {
+	return func(s *Sync) {
+		s.basis = basis
+	}
+}
+
+// Enable byte level edit scripts for literals replacing a known basis range,
+// stored instead of the literal when smaller. Require WithBasis, which already
+// trim single typos to the changed bytes, so they only help when changes are
+// scattered over the replaced range.
+func WithEditScripts() Option {
+	return func(s *Sync) {
+		s.edits = true
+	}
+}
+
+// Calculate delta using bsdiff algorithm instead of rolling blocks, better for
+// executables where addresses shift by small constants. Require WithBasis,
+// rolling blocks are used without it.
+func WithBSDiff() Option {
+	return func(s *Sync) {
+		s.bsdiff = true
+	}
+}
+
+// Set metrics to report block matching events, see also Sync.Counters.
+func WithMetrics(m Metrics) Option {
+	return func(s *Sync) {
+		s.metrics = m
+	}
+}
+
+// Set seed to key block checksums in signature, see NewSeed.
+// Delta use the seed recorded in signature.
+func WithSeed(seed []byte) Option {
+	return func(s *Sync) {
+		s.seed = seed
+		s.table = seedTable(seed)
+	}
+}
+
+// Set input ranges known to be zeros, eg. file holes, sorted by start.
+// Signature don't hash blocks inside them and delta skip them as zero runs.
+func WithHoles(holes []Range) Option {
+	return func(s *Sync) {
+		s.holes = holes
+	}
+}
@@ -0,0 +1,393 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Operation types for delta stream
+type OpType uint8
+
+const (
+	OpCopy    OpType = iota // Copy range from source block
+	OpLiteral               // Write literal bytes
+	OpEdit                  // Apply edit script over source range
+	OpAdd                   // Add bytes to source range, bsdiff
+	OpZero                  // Write zero bytes run, could be left as a hole
+)
+
+// Op describe a single step to rebuild target from source
+type Op struct {
+	Type   OpType // Operation type
+	Index  int64  // Source block index for copy
+	Start  int64  // Start position in source for copy
+	Offset int64  // End position in source for copy
+	Lit    []byte // Literal bytes to write
+	Size   int64  // Raw literal size if compressed, 0 if raw, or zero run size
+	Edits  []Edit // Edit script over source range
+}
+
+// Return output bytes written by operation
+func (o Op) Len() int64 {
+	switch {
+	case o.Type == OpCopy || o.Type == OpAdd:
+		return o.Offset - o.Start
+	case o.Type == OpZero:
+		return o.Size
+	case o.Type == OpEdit:
+		var size int64
+		for _, edit := range o.Edits {
+			size += int64(edit.Keep + len(edit.Insert))
+		}
+
+		return size
+	case o.Size > 0:
+		return o.Size
+	}
+
+	return int64(len(o.Lit))
+}
+
+// Stream keep ordered delta operations
+// plus the strong checksum of the expected target
+type Stream struct {
+	Checksum    string       // Strong checksum of the complete target
+	Compression Compression  // Compression algorithm for literal data
+	Ops         []Op         // Ordered operations to rebuild target
+	Checkpoints []Checkpoint // Ordered positions to resume patching
+}
+
+// ErrChecksumMismatch is returned when patched output differs from expected target
+type ErrChecksumMismatch struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch: expected %s, got %s", e.Expected, e.Actual)
+}
+
+// HoleWriter could skip zero runs leaving holes instead of writing them, see OpZero
+type HoleWriter interface {
+	io.Writer
+	Hole(size int64) error
+}
+
+// Patcher apply delta operations one by one over source,
+// verifying checkpoints so an interrupted patch could be resumed.
+type Patcher struct {
+	source io.ReaderAt
+	out    io.Writer
+	codec  Codec      // Literal decompression codec
+	dict   dictionary // Matched blocks since last checkpoint
+	hash   hash.Hash  // Running output checksum
+	op     int        // Next operation index
+	offset int64      // Output bytes written
+	last   Checkpoint // Last verified checkpoint
+}
+
+// Factory function
+// Resume patching from checkpoint, out must continue right after checkpoint offset.
+// Use zero Checkpoint to start from scratch.
+func NewPatcher(source io.ReaderAt, out io.Writer, from Checkpoint, compression Compression) (*Patcher, error) {
+	h, err := restore(from.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, ok := codecFor(compression)
+	if compression != CompressNone && !ok {
+		return nil, fmt.Errorf("no codec registered for compression %d", compression)
+	}
+
+	return &Patcher{
+		source: source,
+		out:    out,
+		codec:  codec,
+		hash:   h,
+		op:     from.Op,
+		offset: from.Offset,
+		last:   from,
+	}, nil
+}
+
+// Apply next operation writing into output
+func (p *Patcher) Apply(op Op) error {
+	writer := io.MultiWriter(p.out, p.hash)
+
+	switch op.Type {
+	case OpLiteral:
+		lit, err := p.literal(op)
+		if err != nil {
+			return err
+		}
+
+		written, err := writer.Write(lit)
+		p.offset += int64(written)
+		if err != nil {
+			return err
+		}
+	case OpCopy:
+		size := op.Offset - op.Start
+		section := io.NewSectionReader(p.source, op.Start, size)
+		written, err := io.Copy(io.MultiWriter(writer, &p.dict), section)
+		p.offset += written
+		if err != nil {
+			return err
+		}
+
+		// Block out of source range, wrong source file?
+		if written != size {
+			return errors.New("copy range out of source bounds")
+		}
+	case OpAdd:
+		written, err := p.add(writer, op)
+		p.offset += int64(written)
+		if err != nil {
+			return err
+		}
+	case OpEdit:
+		written, err := p.edit(writer, op)
+		p.offset += written
+		if err != nil {
+			return err
+		}
+	case OpZero:
+		written, err := p.zero(op.Size)
+		p.offset += written
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown delta operation %d", op.Type)
+	}
+
+	p.op++
+	return nil
+}
+
+// Add bytewise difference to source range and return written bytes
+func (p *Patcher) add(writer io.Writer, op Op) (int, error) {
+	diff, err := p.literal(op)
+	if err != nil {
+		return 0, err
+	}
+
+	// Range is bounded by add data already in memory
+	size, err := sourceRange(op, int64(len(diff)))
+	if err != nil || size != int64(len(diff)) {
+		return 0, errors.New("add range does not match add data")
+	}
+
+	data := make([]byte, size)
+	read, err := p.source.ReadAt(data, op.Start)
+	if read != len(data) {
+		return 0, errors.New("add range out of source bounds")
+	}
+
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	for i := range data {
+		data[i] += diff[i]
+	}
+
+	return writer.Write(data)
+}
+
+// Write zero run, as a hole if output support it, and return written bytes
+func (p *Patcher) zero(size int64) (int64, error) {
+	if size < 0 {
+		return 0, errors.New("negative zero run size")
+	}
+
+	zeros(p.hash, size)
+	if out, ok := p.out.(HoleWriter); ok {
+		if err := out.Hole(size); err != nil {
+			return 0, err
+		}
+
+		return size, nil
+	}
+
+	return zeros(p.out, size)
+}
+
+// Return source range size of operation, bounded by max before anything is allocated
+func sourceRange(op Op, max int64) (int64, error) {
+	if op.Start < 0 || op.Start > op.Offset || op.Offset-op.Start > max {
+		return 0, errors.New("invalid operation source range")
+	}
+
+	return op.Offset - op.Start, nil
+}
+
+// Apply edit script over source range and return written bytes
+func (p *Patcher) edit(writer io.Writer, op Op) (int64, error) {
+	// Edit scripts are only computed for bounded ranges
+	size, err := sourceRange(op, maxEditSize)
+	if err != nil {
+		return 0, err
+	}
+
+	replaced := make([]byte, size)
+	read, err := p.source.ReadAt(replaced, op.Start)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	if read != len(replaced) {
+		return 0, errors.New("edit range out of source bounds")
+	}
+
+	var written int64
+	cursor := 0
+	for _, edit := range op.Edits {
+		if edit.Keep < 0 || edit.Delete < 0 || cursor+edit.Keep+edit.Delete > len(replaced) {
+			return written, errors.New("edit script out of source range")
+		}
+
+		kept, err := writer.Write(replaced[cursor : cursor+edit.Keep])
+		written += int64(kept)
+		if err != nil {
+			return written, err
+		}
+
+		inserted, err := writer.Write(edit.Insert)
+		written += int64(inserted)
+		if err != nil {
+			return written, err
+		}
+
+		cursor += edit.Keep + edit.Delete
+	}
+
+	return written, nil
+}
+
+// Return raw literal bytes for operation
+func (p *Patcher) literal(op Op) ([]byte, error) {
+	if op.Size == 0 {
+		return op.Lit, nil
+	}
+
+	if p.codec == nil {
+		return nil, errors.New("compressed literal in uncompressed delta")
+	}
+
+	lit, err := p.codec.Decompress(op.Lit, p.dict.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(lit)) != op.Size {
+		return nil, errors.New("decompressed literal size mismatch")
+	}
+
+	return lit, nil
+}
+
+// Verify output written so far match checkpoint and keep it as last verified
+func (p *Patcher) Checkpoint(cp Checkpoint) error {
+	if cp.Op != p.op || cp.Offset != p.offset {
+		return errors.New("checkpoint out of sequence")
+	}
+
+	expected, err := restore(cp.Hash)
+	if err != nil {
+		return err
+	}
+
+	if sum(expected) != sum(p.hash) {
+		return &ErrChecksumMismatch{Expected: sum(expected), Actual: sum(p.hash)}
+	}
+
+	p.last = cp
+	p.dict.Reset() // Dictionary restart at checkpoints
+	return nil
+}
+
+// Return last verified checkpoint to resume from
+func (p *Patcher) Last() Checkpoint { return p.last }
+
+// Verify output written match expected target checksum
+func (p *Patcher) Verify(checksum string) error {
+	if actual := sum(p.hash); actual != checksum {
+		return &ErrChecksumMismatch{Expected: checksum, Actual: actual}
+	}
+
+	return nil
+}
+
+// Apply delta stream over source and write the rebuilt target into out.
+// Return ErrChecksumMismatch if written output differs from expected target checksum.
+func Patch(source io.ReaderAt, delta Stream, out io.Writer) error {
+	return Resume(source, delta, out, Checkpoint{})
+}
+
+// Apply delta stream operations after checkpoint over source, out must continue
+// right after checkpoint offset. Return ErrChecksumMismatch if any checkpoint or
+// the complete output differs from expected target checksum.
+func Resume(source io.ReaderAt, delta Stream, out io.Writer, from Checkpoint) error {
+	patcher, err := NewPatcher(source, out, from, delta.Compression)
+	if err != nil {
+		return err
+	}
+
+	checkpoints := delta.Checkpoints
+	for i := from.Op; i < len(delta.Ops); i++ {
+		// Verify checkpoints reached before next operation
+		for len(checkpoints) > 0 && checkpoints[0].Op <= i {
+			if checkpoints[0].Op == i && i > from.Op {
+				if err := patcher.Checkpoint(checkpoints[0]); err != nil {
+					return err
+				}
+			}
+
+			checkpoints = checkpoints[1:]
+		}
+
+		if err := patcher.Apply(delta.Ops[i]); err != nil {
+			return err
+		}
+	}
+
+	return patcher.Verify(delta.Checksum)
+}
+
+// Check if delta could be applied over its own source file.
+// Data is written sequentially so it is safe only if every copy operation
+// read from source at or ahead of the current output position.
+func InPlace(delta Stream) bool {
+	var position int64
+	for _, op := range delta.Ops {
+		// Edit scripts could write ahead of source bytes still to read
+		reads := op.Type == OpCopy || op.Type == OpAdd
+		if op.Type == OpEdit || reads && op.Start < position {
+			return false
+		}
+
+		position += op.Len()
+	}
+
+	return true
+}
+
+// Write size zero bytes
+func zeros(w io.Writer, size int64) (int64, error) {
+	return io.CopyN(w, zeroReader{}, size)
+}
+
+// Reader of endless zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+
+	return len(p), nil
+}
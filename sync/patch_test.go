@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func CalculateStream(a []byte, b []byte) Stream {
+	sync := New(1 << 4) // 16 bytes
+	bufioA := bufio.NewReader(bytes.NewReader(a))
+	bufioB := bufio.NewReader(bytes.NewReader(b))
+
+	sig := sync.BuildSigTable(bufioA)
+	return sync.Diff(sig, bufioB)
+}
+
+func TestPatch(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	targets := [][]byte{
+		[]byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash"),
+		[]byte("i here guys how are you doing this is a mall test chunk split and rolling hash"),
+		[]byte("ow are you doing this is a small split and rolling hash"),
+		[]byte("i am here guys   how are you doing    test for chunk split and rolling hash!!"),
+		[]byte("ow are you doingow are you doing"),
+		[]byte(""),
+	}
+
+	for _, b := range targets {
+		var out bytes.Buffer
+		delta := CalculateStream(a, b)
+		if err := Patch(bytes.NewReader(a), delta, &out); err != nil {
+			t.Fatalf("Expected patch without errors, got %v", err)
+		}
+
+		if !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("Expected patched output %q equal to target %q", out.Bytes(), b)
+		}
+	}
+}
+
+func TestPatchChecksumMismatch(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	b := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash")
+	delta := CalculateStream(a, b)
+
+	// Wrong source file with same size
+	wrong := bytes.ToUpper(a)
+	err := Patch(bytes.NewReader(wrong), delta, new(bytes.Buffer))
+
+	var mismatch *ErrChecksumMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected ErrChecksumMismatch patching over wrong source, got %v", err)
+	}
+}
+
+func TestPatchOutOfBounds(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	b := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash")
+	delta := CalculateStream(a, b)
+
+	// Truncated source can't satisfy copy ranges
+	err := Patch(bytes.NewReader(a[:20]), delta, new(bytes.Buffer))
+	if err == nil {
+		t.Fatal("Expected error patching over truncated source")
+	}
+}
+
+func TestPatchInvalidRange(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	ops := map[string]Op{
+		"reversed edit": {Type: OpEdit, Start: 10, Offset: 5},
+		"huge edit":     {Type: OpEdit, Start: 0, Offset: 1 << 40},
+		"edit past end": {Type: OpEdit, Start: 80, Offset: 100, Edits: []Edit{{Keep: 20}}},
+		"reversed add":  {Type: OpAdd, Start: 10, Offset: 5, Lit: []byte{1}},
+		"huge add":      {Type: OpAdd, Start: 0, Offset: 1 << 40, Lit: []byte{1}},
+		"add past end":  {Type: OpAdd, Start: 80, Offset: 100, Lit: make([]byte, 20)},
+	}
+
+	for name, op := range ops {
+		if err := Patch(bytes.NewReader(a), Stream{Ops: []Op{op}}, new(bytes.Buffer)); err == nil {
+			t.Errorf("Expected error patching %s operation", name)
+		}
+	}
+}
+
+func TestInPlace(t *testing.T) {
+	backward := Stream{Ops: []Op{
+		{Type: OpCopy, Index: 1, Start: 16, Offset: 32},
+		{Type: OpLiteral, Lit: []byte("added")},
+		{Type: OpCopy, Index: 2, Start: 32, Offset: 48},
+	}}
+
+	forward := Stream{Ops: []Op{
+		{Type: OpLiteral, Lit: []byte("added")},
+		{Type: OpCopy, Index: 0, Start: 0, Offset: 16},
+	}}
+
+	if !InPlace(backward) {
+		t.Errorf("Expected delta moving data backward to be applied in place")
+	}
+
+	if InPlace(forward) {
+		t.Errorf("Expected delta moving data forward not to be applied in place")
+	}
+}
+
+func TestResume(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 8)
+	b := bytes.Replace(a, []byte("small"), []byte("big"), 3)
+
+	sync := New(1<<4, WithCheckpoint(1<<5))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	if len(delta.Checkpoints) == 0 {
+		t.Fatal("Expected checkpoints in delta stream")
+	}
+
+	for _, cp := range delta.Checkpoints {
+		// Output already written until checkpoint
+		out := bytes.NewBuffer(append([]byte{}, b[:cp.Offset]...))
+		if err := Resume(bytes.NewReader(a), delta, out, cp); err != nil {
+			t.Fatalf("Expected resume from op %d without errors, got %v", cp.Op, err)
+		}
+
+		if !bytes.Equal(out.Bytes(), b) {
+			t.Errorf("Expected resumed output from op %d equal to target", cp.Op)
+		}
+	}
+}
+
+func TestCheckpointMismatch(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 4)
+	b := bytes.Replace(a, []byte("small"), []byte("big"), 1)
+
+	sync := New(1<<4, WithCheckpoint(1<<5))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	// Wrong source is detected at first checkpoint
+	patcher, _ := NewPatcher(bytes.NewReader(bytes.ToUpper(a)), new(bytes.Buffer), Checkpoint{}, CompressNone)
+	cp := delta.Checkpoints[0]
+	for _, op := range delta.Ops[:cp.Op] {
+		patcher.Apply(op)
+	}
+
+	var mismatch *ErrChecksumMismatch
+	if err := patcher.Checkpoint(cp); !errors.As(err, &mismatch) {
+		t.Errorf("Expected ErrChecksumMismatch verifying checkpoint, got %v", err)
+	}
+
+	if patcher.Last().Op != 0 {
+		t.Errorf("Expected last verified checkpoint unchanged after mismatch")
+	}
+}
@@ -0,0 +1,180 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sort"
+)
+
+// Range of bytes [Start, Offset) in a file
+type Range struct {
+	Start  int64
+	Offset int64
+}
+
+// Return source ranges not read by any delta operation, sorted.
+// A finer signature over them could match delta literals, see Refine.
+func Unmatched(sig Signature, delta Stream) []Range {
+	var read []Range
+	for _, op := range delta.Ops {
+		if op.Type != OpLiteral && op.Type != OpZero {
+			read = append(read, Range{op.Start, op.Offset})
+		}
+	}
+
+	sort.Slice(read, func(i, j int) bool { return read[i].Start < read[j].Start })
+
+	// Source size is unknown, last block could be short, ranges are clipped by BuildRangeSig
+	var unmatched []Range
+	var cursor int64
+	for _, r := range append(read, Range{int64(len(sig.Tables)) * int64(sig.BlockSize), 0}) {
+		if r.Start > cursor {
+			unmatched = append(unmatched, Range{cursor, r.Start})
+		}
+
+		if r.Offset > cursor {
+			cursor = r.Offset
+		}
+	}
+
+	return unmatched
+}
+
+// Build signature only for source blocks overlapping ranges, tables for any other
+// block are left empty and never matched. Ranges must be sorted, see Unmatched.
+func (s *Sync) BuildRangeSig(source io.ReaderAt, ranges []Range) (Signature, error) {
+	s = s.sized(Signature{})
+	sig := Signature{BlockSize: s.blockSize, Seed: s.seed}
+	block := make([]byte, s.blockSize)
+	for _, r := range ranges {
+		// Blocks already hashed by previous range are skipped
+		first := int(r.Start / int64(s.blockSize))
+		if first < len(sig.Tables) {
+			first = len(sig.Tables)
+		}
+
+		for index := first; s.position(index) < r.Offset; index++ {
+			read, err := source.ReadAt(block, s.position(index))
+			if err != nil && err != io.EOF {
+				return sig, err
+			}
+
+			if read == 0 {
+				return sig, nil
+			}
+
+			for len(sig.Tables) < index {
+				sig.Tables = append(sig.Tables, Table{})
+			}
+
+			chunk := block[:read]
+			sig.Tables = append(sig.Tables, Table{
+				Weak:   s.rolling().Write(chunk).Sum(),
+				Strong: keyedStrong(s.seed, chunk),
+			})
+		}
+	}
+
+	return sig, nil
+}
+
+// Refine delta matching its literals against a finer signature, usually built over
+// source ranges the delta does not read, see Unmatched and BuildRangeSig.
+// Target is read again to rebuild the stream, return one delta combining both passes.
+func (s *Sync) Refine(delta Stream, fine Signature, target *bufio.Reader) (Stream, error) {
+	// Fine pass only collect literal and copy operations
+	raw := *s
+	raw.compression = CompressNone
+	raw.checkpoint = 0
+	raw.basis = nil
+	raw.edits = false
+	raw.bsdiff = false
+	// Literals are read from offset 0, target holes don't apply to them
+	raw.holes = nil
+	raw.dense = true
+
+	stream := newStreamer(s.checkpoint, s.compression)
+	var literal []byte
+	// Replay delta dictionary to decompress add data
+	var dict dictionary
+	checkpoints := delta.Checkpoints
+	codec, _ := codecFor(delta.Compression)
+	for i, op := range delta.Ops {
+		for len(checkpoints) > 0 && checkpoints[0].Op <= i {
+			if checkpoints[0].Op == i {
+				dict.Reset()
+			}
+
+			checkpoints = checkpoints[1:]
+		}
+
+		// Zero runs are kept as is
+		if op.Type == OpZero {
+			if _, err := discard(target, op.Size); err != nil {
+				return Stream{}, err
+			}
+
+			raw.refine(stream, fine, literal)
+			literal = nil
+			stream.zero(op.Size)
+			continue
+		}
+
+		data := make([]byte, op.Len())
+		if _, err := io.ReadFull(target, data); err != nil {
+			return Stream{}, err
+		}
+
+		if op.Type == OpLiteral {
+			literal = append(literal, data...)
+			continue
+		}
+
+		raw.refine(stream, fine, literal)
+		literal = nil
+		switch op.Type {
+		case OpCopy:
+			dict.Write(data)
+			stream.copy(op.Index, op.Start, op.Offset, data)
+		case OpAdd:
+			// Add data is compressed again against refined stream dictionary
+			diff, err := addData(codec, op, dict.Bytes())
+			if err != nil {
+				return Stream{}, err
+			}
+
+			stream.add(s.compressOp(stream, Op{Type: OpAdd, Start: op.Start, Offset: op.Offset, Lit: diff}), data)
+		default:
+			stream.add(op, data)
+		}
+	}
+
+	raw.refine(stream, fine, literal)
+	refined := stream.done()
+	if refined.Checksum != delta.Checksum {
+		return Stream{}, &ErrChecksumMismatch{Expected: delta.Checksum, Actual: refined.Checksum}
+	}
+
+	return refined, nil
+}
+
+// Add literal run matched against fine signature
+func (s *Sync) refine(stream *streamer, fine Signature, literal []byte) {
+	if len(literal) == 0 {
+		return
+	}
+
+	var position int64
+	for _, op := range s.diff(fine, bufio.NewReader(bytes.NewReader(literal)), s.probe()).Ops {
+		if op.Type == OpCopy {
+			stream.copy(op.Index, op.Start, op.Offset, literal[position:position+op.Len()])
+		} else if op.Type == OpZero {
+			stream.zero(op.Size)
+		} else {
+			stream.literal(op.Lit)
+		}
+
+		position += op.Len()
+	}
+}
@@ -0,0 +1,212 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Return encoded size
+func encodedSize(t *testing.T, v interface{}) int {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Len()
+}
+
+func TestUnmatched(t *testing.T) {
+	sig := Signature{BlockSize: 4, Tables: make([]Table, 5)}
+	delta := Stream{Ops: []Op{
+		{Type: OpCopy, Start: 4, Offset: 8},
+		{Type: OpLiteral, Lit: []byte("abc")},
+		{Type: OpCopy, Start: 4, Offset: 8},
+		{Type: OpCopy, Start: 12, Offset: 14},
+	}}
+
+	expected := []Range{{0, 4}, {8, 12}, {14, 20}}
+	if unmatched := Unmatched(sig, delta); !reflect.DeepEqual(unmatched, expected) {
+		t.Errorf("Expected unmatched ranges %v, got %v", expected, unmatched)
+	}
+}
+
+func TestBuildRangeSig(t *testing.T) {
+	data := []byte("i am here guys how are you doing")
+	sync := New(4)
+	full := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(data)))
+	sig, err := sync.BuildRangeSig(bytes.NewReader(data), []Range{{5, 9}, {8, 12}, {30, 40}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks 1, 2 and the short last one
+	for i, table := range sig.Tables {
+		hashed := i == 1 || i == 2 || i == 7
+		if hashed && table != full.Tables[i] || !hashed && table != (Table{}) {
+			t.Errorf("Expected table %d hashed only if it overlap ranges, got %v", i, table)
+		}
+	}
+
+	if len(sig.Tables) != len(full.Tables) {
+		t.Errorf("Expected %d tables, got %d", len(full.Tables), len(sig.Tables))
+	}
+}
+
+func TestRefine(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<20)
+	random.Read(basis)
+	target := append([]byte{}, basis...)
+	for _, at := range []int{1000, 300000, 700001, 700100, 1040000} {
+		target[at] ^= 0xff
+	}
+
+	// Shifted content inside a coarse block is matched by fine pass
+	target = append(append(append([]byte{}, target[:500000]...), "inserted"...), target[500000:]...)
+
+	coarse, fine := New(1<<14), New(1<<9)
+	coarseSig := coarse.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	coarseDelta := coarse.Diff(coarseSig, bufio.NewReader(bytes.NewReader(target)))
+
+	fineSig, err := fine.BuildRangeSig(bytes.NewReader(basis), Unmatched(coarseSig, coarseDelta))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refined, err := coarse.Refine(coarseDelta, fineSig, bufio.NewReader(bytes.NewReader(target)))
+	if err != nil {
+		t.Fatalf("Expected delta refined, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), refined, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+		t.Fatalf("Expected refined delta patched, got %v", err)
+	}
+
+	// Only blocks of edited coarse blocks are hashed
+	hashed := 0
+	for _, table := range fineSig.Tables {
+		if table.Strong != "" {
+			hashed++
+		}
+	}
+
+	if hashed > 6*(1<<14)/(1<<9) {
+		t.Errorf("Expected fine tables only for edited coarse blocks, got %d", hashed)
+	}
+
+	fineDelta := fine.Diff(fine.BuildSigTable(bufio.NewReader(bytes.NewReader(basis))), bufio.NewReader(bytes.NewReader(target)))
+	size := encodedSize(t, refined)
+	if size >= encodedSize(t, coarseDelta) || size >= encodedSize(t, fineDelta) {
+		t.Errorf(
+			"Expected refined delta smaller than coarse and fine ones, got %d, %d and %d",
+			size, encodedSize(t, coarseDelta), encodedSize(t, fineDelta),
+		)
+	}
+}
+
+func TestRefineHoles(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<16)
+	random.Read(basis)
+
+	// New data, a hole, then shifted basis edited too often for coarse blocks
+	target := make([]byte, 1<<13)
+	random.Read(target[:1<<12])
+	tail := append([]byte{}, basis[100:100+1<<14]...)
+	for at := 0; at < len(tail); at += 1 << 11 {
+		tail[at] ^= 0xff
+	}
+
+	target = append(target, tail...)
+	holes := []Range{{Start: 1 << 12, Offset: 1 << 13}}
+
+	coarse, fine := New(1<<12, WithHoles(holes)), New(1<<9)
+	coarseSig := coarse.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	coarseDelta := coarse.Diff(coarseSig, bufio.NewReader(bytes.NewReader(target)))
+	fineSig := fine.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+
+	refined, err := coarse.Refine(coarseDelta, fineSig, bufio.NewReader(bytes.NewReader(target)))
+	if err != nil {
+		t.Fatalf("Expected sparse target delta refined, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), refined, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+		t.Fatalf("Expected refined delta patched, got %v", err)
+	}
+
+	if len(literals(refined)) >= len(literals(coarseDelta)) {
+		t.Errorf("Expected fine matches after hole, got %d literal bytes", len(literals(refined)))
+	}
+}
+
+func TestRefineCompressed(t *testing.T) {
+	basis := bytes.Repeat([]byte("i am here guys how are you doing, "), 200)
+	target := bytes.Replace(basis, []byte("guys how"), []byte("GUYS HOW"), 3)
+
+	options := []Option{WithCompression(CompressDeflate), WithCheckpoint(1 << 10), WithSeed([]byte("seed"))}
+	coarse := New(1<<10, options...)
+	sig := coarse.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	delta := coarse.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+
+	fine, _ := New(1<<6, options...).BuildRangeSig(bytes.NewReader(basis), Unmatched(sig, delta))
+	refined, err := coarse.Refine(delta, fine, bufio.NewReader(bytes.NewReader(target)))
+	if err != nil || refined.Compression != CompressDeflate || len(refined.Checkpoints) == 0 {
+		t.Fatalf("Expected compressed refined delta with checkpoints, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), refined, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+		t.Errorf("Expected compressed refined delta patched, got %v", err)
+	}
+
+	// Target must be the one delta was built for
+	if _, err := coarse.Refine(delta, fine, bufio.NewReader(bytes.NewReader(basis))); err == nil {
+		t.Errorf("Expected error refining with another target")
+	}
+}
+
+func TestRefineCompressedAdd(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	words := []string{"rolling ", "hash ", "block ", "delta ", "patch ", "signature "}
+	var basis []byte
+	for len(basis) < 1<<13 {
+		basis = append(basis, words[random.Intn(len(words))]...)
+	}
+
+	// Copy, literal found by fine pass, then add data compressed against copied block
+	copied, literal := basis[:1024], basis[2048:2112]
+	diff := append([]byte{}, basis[:512]...)
+	added := append([]byte{}, basis[4096:4608]...)
+	for i := range added {
+		added[i] += diff[i]
+	}
+
+	coarse := New(1<<10, WithCompression(CompressDeflate))
+	stream := newStreamer(0, CompressDeflate)
+	stream.copy(0, 0, 1024, copied)
+	stream.literal(literal)
+	stream.add(coarse.compressOp(stream, Op{Type: OpAdd, Start: 4096, Offset: 4608, Lit: diff}), added)
+	delta := stream.done()
+	if delta.Ops[2].Size == 0 {
+		t.Fatal("Expected compressed add data")
+	}
+
+	target := append(append(append([]byte{}, copied...), literal...), added...)
+	fine, _ := New(1<<6).BuildRangeSig(bytes.NewReader(basis), []Range{{0, int64(len(basis))}})
+	refined, err := coarse.Refine(delta, fine, bufio.NewReader(bytes.NewReader(target)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Literal became a copy, so add data is compressed against another dictionary
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), refined, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+		t.Errorf("Expected refined delta with compressed add data patched, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package sync
+
+import (
+	"errors"
+	"io"
+	"sort"
+)
+
+// Source bytes read at once for ranges not found in target
+const missingChunk = 1 << 20 // 1 MiB
+
+// Mapping of a source range into target position found in a forward delta
+type mapping struct {
+	start, offset int64  // Source range
+	target        int64  // Target position for source range
+	diff          []byte // Bytewise difference added by bsdiff, nil for exact copies
+}
+
+// Calculate reverse delta, rebuilding source from target, using the forward delta.
+// Every source range copied (or added) into target is copied back from target,
+// so no matching is needed and only source bytes missing in target become literals.
+// Source is the forward delta source, target is not needed since forward
+// delta already describe where source ranges are in target. Source holes, see
+// WithHoles, and zero runs of a block or more become zero operations.
+func (s *Sync) Reverse(source io.ReaderAt, delta Stream) (Stream, error) {
+	s = s.sized(Signature{})
+	mappings, err := mappings(source, delta)
+	if err != nil {
+		return Stream{}, err
+	}
+
+	// Walk source in order
+	sort.SliceStable(mappings, func(i, j int) bool {
+		return mappings[i].start < mappings[j].start
+	})
+
+	stream := newStreamer(s.checkpoint, s.compression)
+	missing := &zeroSplitter{stream: stream, blockSize: s.blockSize}
+	var cursor int64
+	for _, m := range mappings {
+		// Skip source ranges already covered by a previous mapping
+		if m.offset <= cursor {
+			continue
+		}
+
+		if m.start > cursor {
+			if err := s.missing(missing, source, cursor, m.start); err != nil {
+				return Stream{}, err
+			}
+
+			cursor = m.start
+		}
+
+		missing.flush()
+		skip := cursor - m.start
+		data, err := readRange(source, cursor, m.offset)
+		if err != nil {
+			return Stream{}, err
+		}
+
+		if m.diff == nil {
+			stream.copy(0, m.target+skip, m.target+skip+int64(len(data)), data)
+		} else {
+			// Subtract the forward difference
+			diff := make([]byte, len(data))
+			for i := range diff {
+				diff[i] = -m.diff[skip+int64(i)]
+			}
+
+			op := Op{Type: OpAdd, Start: m.target + skip, Offset: m.target + skip + int64(len(data)), Lit: diff}
+			stream.add(s.compressOp(stream, op), data)
+		}
+
+		cursor = m.offset
+	}
+
+	// Remaining source bytes not found in target
+	if err := s.missing(missing, source, cursor, -1); err != nil {
+		return Stream{}, err
+	}
+
+	missing.flush()
+	return stream.done(), nil
+}
+
+// Add source range [start, offset) not found in target reading it in chunks,
+// negative offset read up to end of source. Holes are not read.
+func (s *Sync) missing(z *zeroSplitter, source io.ReaderAt, start, offset int64) error {
+	for offset < 0 || start < offset {
+		if hole := s.hole(start); hole > 0 {
+			if offset >= 0 && start+hole > offset {
+				hole = offset - start
+			}
+
+			z.zero(hole)
+			start += hole
+			continue
+		}
+
+		size := int64(missingChunk)
+		if offset >= 0 && offset-start < size {
+			size = offset - start
+		}
+
+		if next, ok := s.nextHole(start); ok && next-start < size {
+			size = next - start
+		}
+
+		data := make([]byte, size)
+		read, err := source.ReadAt(data, start)
+		z.write(data[:read])
+		start += int64(read)
+		if err == io.EOF && offset < 0 {
+			return nil
+		}
+
+		if read < len(data) {
+			if err == nil || err == io.EOF {
+				err = errors.New("range out of source bounds")
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Collect source ranges and their target positions from delta operations
+func mappings(source io.ReaderAt, delta Stream) ([]mapping, error) {
+	var mappings []mapping
+	// Replay patcher dictionary to decompress add data
+	var dict dictionary
+	checkpoints := delta.Checkpoints
+	codec, _ := codecFor(delta.Compression)
+	var position int64
+	for i, op := range delta.Ops {
+		for len(checkpoints) > 0 && checkpoints[0].Op <= i {
+			if checkpoints[0].Op == i {
+				dict.Reset()
+			}
+
+			checkpoints = checkpoints[1:]
+		}
+
+		switch op.Type {
+		case OpCopy:
+			mappings = append(mappings, mapping{start: op.Start, offset: op.Offset, target: position})
+			if codec != nil {
+				data, err := readRange(source, op.Start, op.Offset)
+				if err != nil {
+					return nil, err
+				}
+
+				dict.Write(data)
+			}
+		case OpAdd:
+			diff, err := addData(codec, op, dict.Bytes())
+			if err != nil {
+				return nil, err
+			}
+
+			mappings = append(mappings, mapping{start: op.Start, offset: op.Offset, target: position, diff: diff})
+		case OpEdit:
+			// Kept bytes are exact copies
+			cursor, output := op.Start, position
+			for _, edit := range op.Edits {
+				if edit.Keep > 0 {
+					mappings = append(mappings, mapping{start: cursor, offset: cursor + int64(edit.Keep), target: output})
+				}
+
+				cursor += int64(edit.Keep + edit.Delete)
+				output += int64(edit.Keep + len(edit.Insert))
+			}
+		}
+
+		position += op.Len()
+	}
+
+	return mappings, nil
+}
+
+// Read source range
+func readRange(source io.ReaderAt, start, offset int64) ([]byte, error) {
+	data := make([]byte, offset-start)
+	read, err := source.ReadAt(data, start)
+	if read == len(data) {
+		return data, nil
+	}
+
+	if err == nil || err == io.EOF {
+		err = errors.New("range out of source bounds")
+	}
+
+	return nil, err
+}
+
+// Return raw bytewise difference of add operation, decompressed using dictionary
+func addData(codec Codec, op Op, dict []byte) ([]byte, error) {
+	diff := op.Lit
+	if op.Size > 0 {
+		if codec == nil {
+			return nil, errors.New("compressed add data in uncompressed delta")
+		}
+
+		var err error
+		if diff, err = codec.Decompress(op.Lit, dict); err != nil {
+			return nil, err
+		}
+	}
+
+	if int64(len(diff)) != op.Offset-op.Start {
+		return nil, errors.New("add data size mismatch")
+	}
+
+	return diff, nil
+}
@@ -0,0 +1,143 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	targets := [][]byte{
+		[]byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash"),
+		[]byte("i here guys how are you doing this is a mall test chunk split and rolling hash"),
+		[]byte("ow are you doing this is a small split and rolling hash"),
+		[]byte("ow are you doingow are you doing"),
+		[]byte(""),
+	}
+
+	modes := [][]Option{
+		nil,
+		{WithBasis(bytes.NewReader(a))},
+		{WithBasis(bytes.NewReader(a)), WithEditScripts()},
+		{WithBasis(bytes.NewReader(a)), WithBSDiff(), WithCompression(CompressDeflate)},
+		{WithCheckpoint(1 << 4), WithCompression(CompressDeflate)},
+	}
+
+	for _, b := range targets {
+		for _, options := range modes {
+			sync := New(1<<4, options...)
+			sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+			forward := sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+			reverse, err := sync.Reverse(bytes.NewReader(a), forward)
+			if err != nil {
+				t.Fatalf("Expected reverse delta without errors, got %v", err)
+			}
+
+			// Rollback from v2 to v1
+			var out bytes.Buffer
+			if err := Patch(bytes.NewReader(b), reverse, &out); err != nil {
+				t.Fatalf("Expected reverse patch without errors, got %v", err)
+			}
+
+			if !bytes.Equal(out.Bytes(), a) {
+				t.Errorf("Expected reverse patched output %q equal to source", out.Bytes())
+			}
+		}
+	}
+}
+
+func TestReverseChain(t *testing.T) {
+	versions := [][]byte{
+		[]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash"),
+		[]byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash"),
+		[]byte("i am here guys how are you doingadded this is a big test for chunk split and rolling hash"),
+		[]byte("hey i am here guys how are you doingadded this is a big test for split and rolling hash"),
+	}
+
+	// Keep only latest version plus reverse deltas
+	sync := New(1 << 4)
+	var reverses []Stream
+	for i := 1; i < len(versions); i++ {
+		sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(versions[i-1])))
+		forward := sync.Diff(sig, bufio.NewReader(bytes.NewReader(versions[i])))
+		reverse, _ := sync.Reverse(bytes.NewReader(versions[i-1]), forward)
+		reverses = append(reverses, reverse)
+	}
+
+	current := versions[len(versions)-1]
+	for i := len(reverses) - 1; i >= 0; i-- {
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(current), reverses[i], &out); err != nil {
+			t.Fatalf("Expected rollback to version %d without errors, got %v", i, err)
+		}
+
+		current = out.Bytes()
+		if !bytes.Equal(current, versions[i]) {
+			t.Fatalf("Expected rollback to version %d", i)
+		}
+	}
+}
+
+func TestReverseBinaries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping binary corpus in short mode")
+	}
+
+	a, _ := os.ReadFile("testdata/bsdiff/v1.bin")
+	b, _ := os.ReadFile("testdata/bsdiff/v2.bin")
+	forward := bsdiffDelta(a, b, WithCompression(CompressDeflate))
+
+	reverse, err := New(0).Reverse(bytes.NewReader(a), forward)
+	if err != nil {
+		t.Fatalf("Expected reverse delta without errors, got %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(b), reverse, &out); err != nil {
+		t.Fatalf("Expected reverse patch without errors, got %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), a) {
+		t.Errorf("Expected reverse patched binary equal to source")
+	}
+}
+
+func TestReverseSparse(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	head, tail := make([]byte, 1<<12), make([]byte, 1<<12)
+	random.Read(head)
+	random.Read(tail)
+
+	// Source hole and trailing zero run are removed in target
+	hole := Range{1 << 12, 1<<12 + 1<<20}
+	source := append(append(append(append([]byte{}, head...), make([]byte, 1<<20)...), tail...), make([]byte, 5000)...)
+	target := append(append([]byte{}, head...), tail...)
+
+	sync := New(1 << 8)
+	forward := sync.DiffBytes(sync.BuildSigTableBytes(source), target)
+
+	for _, holes := range [][]Range{nil, {hole}} {
+		reader := &countingReader{data: source}
+		reverse, err := New(1<<8, WithHoles(holes)).Reverse(reader, forward)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if stats := NewStats(Signature{}, reverse); stats.ZeroBytes != 1<<20+5000 || stats.DeltaBytes > 0 {
+			t.Errorf("Expected zeros missing in target as zero runs, got %d zero and %d delta bytes", stats.ZeroBytes, stats.DeltaBytes)
+		}
+
+		if holes != nil && reader.read > 1<<16 {
+			t.Errorf("Expected source hole not read, got %d bytes read", reader.read)
+		}
+
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(target), reverse, &out); err != nil || !bytes.Equal(out.Bytes(), source) {
+			t.Errorf("Expected reverse delta patched to sparse source, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Seed size in bytes, see NewSeed
+const SeedSize = 16
+
+// Return random seed to key block checksums for a session.
+// Unlike rsync checksum seed it also key the weak checksum, so crafted
+// data can't collide with signature blocks to force a strong checksum per byte.
+func NewSeed() ([]byte, error) {
+	seed := make([]byte, SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+
+	return seed, nil
+}
+
+// Substitution table mapping bytes to keyed values for weak checksum
+type table [256]uint32
+
+// Derive weak checksum substitution table from seed, nil if no seed set
+func seedTable(seed []byte) *table {
+	if len(seed) == 0 {
+		return nil
+	}
+
+	var t table
+	for i := range t {
+		digest := sha256.Sum256(append(append([]byte{}, seed...), byte(i)))
+		t[i] = binary.BigEndian.Uint32(digest[:4]) % M
+	}
+
+	return &t
+}
@@ -0,0 +1,114 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+// Return basis with a block colliding with each target window on plain adler32:
+// adding (1, -1, -1, 1) to the first bytes keep both adler32 sums.
+func craftCollisions(target []byte, blockSize int) []byte {
+	var basis []byte
+	for i := 0; i+blockSize <= len(target); i++ {
+		block := append([]byte{}, target[i:i+blockSize]...)
+		block[0], block[1], block[2], block[3] = block[0]+1, block[1]-1, block[2]-1, block[3]+1
+		basis = append(basis, block...)
+	}
+
+	return basis
+}
+
+func TestSeededAdler32(t *testing.T) {
+	seed := []byte("session seed")
+	data := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+
+	if NewSeededAdler32(nil).Write(data).Sum() != weak(data) {
+		t.Errorf("Expected empty seed to keep plain adler32")
+	}
+
+	if NewSeededAdler32(seed).Write(data).Sum() == weak(data) {
+		t.Errorf("Expected seeded sum to differ from plain adler32")
+	}
+
+	size := 16
+	rolling := NewSeededAdler32(seed)
+	for i, c := range data {
+		rolling = rolling.RollIn(c)
+		if rolling.Count() > size {
+			rolling = rolling.RollOut()
+		}
+
+		if rolling.Count() == size && rolling.Sum() != NewSeededAdler32(seed).Write(data[i+1-size:i+1]).Sum() {
+			t.Fatalf("Expected seeded rolling sum equal to seeded sum at %d", i)
+		}
+	}
+}
+
+func TestSeedCraftedCollisions(t *testing.T) {
+	blockSize := 16
+	random := rand.New(rand.NewSource(1))
+	target := make([]byte, 1<<10)
+	for i := range target {
+		target[i] = byte('b' + random.Intn(24))
+	}
+
+	basis := craftCollisions(target, blockSize)
+	windows := uint64(len(target) - blockSize + 1)
+
+	plain := New(blockSize)
+	sig := plain.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	plain.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+	if plain.Counters().FalsePositives != windows {
+		t.Fatalf("Expected a strong checksum for each of %d windows, got %+v", windows, plain.Counters())
+	}
+
+	seed, err := NewSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seeded := New(blockSize, WithSeed(seed))
+	sig = seeded.BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+	if !bytes.Equal(sig.Seed, seed) {
+		t.Errorf("Expected seed recorded in signature")
+	}
+
+	// Delta side take the seed from signature
+	delta := New(0)
+	stream := delta.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+	if delta.Counters().WeakHits > windows/100 {
+		t.Errorf("Expected crafted collisions to miss seeded weak checksum, got %+v", delta.Counters())
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), stream, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+		t.Errorf("Expected seeded delta to patch target, got %v", err)
+	}
+}
+
+func TestSeedMatchBlocks(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	b := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash")
+
+	sync := New(1<<4, WithSeed([]byte("session seed")))
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := New(1<<4).Delta(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	if string(delta[2].Lit) != "added" {
+		t.Errorf("Expected seeded signature to match unchanged blocks, got %q", delta[2].Lit)
+	}
+}
+
+func TestIndexSharedWeak(t *testing.T) {
+	// "caac" = "bbbb" + (1, -1, -1, 1) keep adler32 sums
+	sync := New(4)
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader([]byte("bbbbcaac"))))
+	indexes := sync.BuildIndexes(sig.Tables)
+
+	if sync.Seek(indexes, weak([]byte("bbbb")), []byte("bbbb")) != 0 ||
+		sync.Seek(indexes, weak([]byte("caac")), []byte("caac")) != 1 {
+		t.Errorf("Expected blocks sharing weak checksum to be found, got %v", indexes)
+	}
+}
@@ -0,0 +1,151 @@
+package sync
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+)
+
+// Fill signature from blocks of data, like BuildSigTable but
+// hashing blocks in place, eg. over a memory mapped file.
+func (s *Sync) BuildSigTableBytes(data []byte) Signature {
+	// Data size is known, use it to pick block size if needed
+	if s.fileSize == 0 {
+		sized := *s
+		sized.fileSize = int64(len(data))
+		s = &sized
+	}
+
+	s = s.sized(Signature{})
+	var signatures []Table
+	var zero *Table
+	for start := 0; start < len(data); start += s.blockSize {
+		end := start + s.blockSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		// Blocks inside holes share the zero block table
+		chunk := data[start:end]
+		if s.hole(int64(start)) >= int64(len(chunk)) {
+			if zero == nil || len(chunk) < s.blockSize {
+				table := s.zeroTable(len(chunk))
+				zero = &table
+			}
+
+			signatures = append(signatures, *zero)
+			continue
+		}
+
+		signatures = append(signatures, Table{
+			Weak:   s.rolling().Write(chunk).Sum(),
+			Strong: keyedStrong(s.seed, chunk),
+		})
+	}
+
+	checksum := sha1.Sum(data)
+	return Signature{
+		BlockSize: s.blockSize,
+		Seed:      s.seed,
+		Checksum:  hex.EncodeToString(checksum[:]),
+		Tables:    signatures,
+	}
+}
+
+// Calculate delta like Diff, rolling over data slice, eg. a memory mapped file.
+// Windows are hashed in place, only literal bytes are copied into the delta
+// so it stays valid after data get released.
+func (s *Sync) DiffBytes(sig Signature, data []byte) Stream {
+	return s.diffSlice(sig, data, s.probe())
+}
+
+// Calculate delta over data slice collecting matching counters in probe
+func (s *Sync) diffSlice(sig Signature, data []byte, counters *probe) Stream {
+	s = s.sized(sig).seeded(sig.Seed)
+	if s.bsdiff && s.basis != nil {
+		if basis, err := readAll(s.basis); err == nil {
+			return s.binaryDiff(basis, append([]byte{}, data...))
+		}
+	}
+
+	indexes := s.BuildIndexes(sig.Tables)
+	stream := newStreamer(s.checkpoint, s.compression)
+	literal := 0 // Start of pending literal
+	start := 0   // Start of window
+	zeros := 0   // Trailing zero bytes in window
+	var weak Adler32
+	for start < len(data) {
+		// Holes are zero runs, skip them without hashing
+		if hole := s.hole(int64(start)); start == literal && hole > 0 && !s.dense {
+			if hole > int64(len(data)-start) {
+				hole = int64(len(data) - start)
+			}
+
+			stream.zero(hole)
+			start += int(hole)
+			literal = start
+			continue
+		}
+
+		end := start + s.blockSize
+		if end > len(data) {
+			break
+		}
+
+		// Window restart right after a match
+		if start == literal {
+			weak = s.rolling().Write(data[start:end])
+			zeros = trailingZeros(data[start:end])
+		}
+
+		// Whole window is zero, add it as zero run instead of looking for a block
+		if zeros >= s.blockSize && !s.dense {
+			lit := append([]byte(nil), data[literal:start]...)
+			if s.basis != nil {
+				lit = s.extend(stream, lit)
+			}
+
+			stream.literal(lit)
+			stream.zero(int64(s.blockSize))
+			start, literal = end, end
+			continue
+		}
+
+		index := counters.seek(indexes, weak.Sum(), data[start:end], s.seed)
+		if ^index == 0 {
+			if end == len(data) {
+				break
+			}
+
+			weak = weak.Roll(data[start], data[end], s.blockSize)
+			zeros++
+			if data[end] != 0 {
+				zeros = 0
+			}
+
+			start++
+			continue
+		}
+
+		block := s.block(index, nil)
+		lit := append([]byte(nil), data[literal:start]...)
+		if s.basis != nil {
+			lit = s.extend(stream, lit)
+			backward := extendBackward(s.basis, lit, block.Start, s.blockSize-1)
+			lit = lit[:len(lit)-backward]
+			block.Start -= int64(backward)
+			start -= backward
+		}
+
+		s.flush(stream, lit, block.Start)
+		stream.copy(int64(index), block.Start, block.Offset, data[start:end])
+		start, literal = end, end
+	}
+
+	lit := append([]byte(nil), data[literal:]...)
+	if s.basis != nil {
+		lit = s.extend(stream, lit)
+	}
+
+	stream.literal(lit)
+	return stream.done()
+}
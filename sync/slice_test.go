@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestSliceSignature(t *testing.T) {
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for _, size := range []int{0, 1 << 4, 1000, 1 << 14} {
+		sync := New(size, WithFileSize(int64(len(data))), WithSeed([]byte("seed")))
+		expected := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(data)))
+		if sig := sync.BuildSigTableBytes(data); !reflect.DeepEqual(sig, expected) {
+			t.Errorf("Expected slice signature equal to reader one for block size %d", size)
+		}
+	}
+
+	// File size is taken from data
+	if sig := New(0).BuildSigTableBytes(data); sig.BlockSize != AutoBlockSize(int64(len(data))) {
+		t.Errorf("Expected auto block size for data, got %d", sig.BlockSize)
+	}
+}
+
+func TestSliceDiff(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<14)
+	random.Read(basis)
+	target := append([]byte{}, basis[:3000]...)
+	target = append(target, "inserted"...)
+	target = append(target, basis[3000:9000]...)
+	target = append(target, basis[9100:]...)
+	copy(target[12000:], "replaced")
+
+	cases := map[string][]Option{
+		"plain":       nil,
+		"seed":        {WithSeed([]byte("seed"))},
+		"compression": {WithCompression(CompressDeflate), WithCheckpoint(1 << 12)},
+		"basis":       {WithBasis(bytes.NewReader(basis))},
+		"edits":       {WithBasis(bytes.NewReader(basis)), WithEditScripts()},
+		"bsdiff":      {WithBasis(bytes.NewReader(basis)), WithBSDiff()},
+	}
+
+	for name, options := range cases {
+		sync := New(1<<8, options...)
+		sig := sync.BuildSigTableBytes(basis)
+		expected := sync.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+
+		data := append([]byte{}, target...)
+		delta := sync.DiffBytes(sig, data)
+		if !reflect.DeepEqual(delta, expected) {
+			t.Errorf("Expected %s slice delta equal to reader one", name)
+		}
+
+		// Delta must not share memory with released data
+		for i := range data {
+			data[i] = 0
+		}
+
+		var out bytes.Buffer
+		if err := Patch(bytes.NewReader(basis), delta, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+			t.Errorf("Expected %s slice delta patched after data released, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"bufio"
+	"sort"
+)
+
+// Return zero run length starting at position, when it is inside a hole
+func (s *Sync) hole(position int64) int64 {
+	i := sort.Search(len(s.holes), func(i int) bool { return s.holes[i].Offset > position })
+	if i == len(s.holes) || s.holes[i].Start > position {
+		return 0
+	}
+
+	return s.holes[i].Offset - position
+}
+
+// Return start of first hole after position
+func (s *Sync) nextHole(position int64) (int64, bool) {
+	i := sort.Search(len(s.holes), func(i int) bool { return s.holes[i].Start > position })
+	if i == len(s.holes) {
+		return 0, false
+	}
+
+	return s.holes[i].Start, true
+}
+
+// Return table for zero block of size, same for every block inside holes
+func (s *Sync) zeroTable(size int) Table {
+	block := make([]byte, size)
+	return Table{Weak: s.rolling().Write(block).Sum(), Strong: keyedStrong(s.seed, block)}
+}
+
+// Return count of trailing zero bytes in data
+func trailingZeros(data []byte) int {
+	count := 0
+	for count < len(data) && data[len(data)-1-count] == 0 {
+		count++
+	}
+
+	return count
+}
+
+// Discard size bytes from reader, return discarded bytes
+func discard(reader *bufio.Reader, size int64) (int64, error) {
+	var discarded int64
+	for discarded < size {
+		chunk := size - discarded
+		if chunk > 1<<30 {
+			chunk = 1 << 30
+		}
+
+		n, err := reader.Discard(int(chunk))
+		discarded += int64(n)
+		if err != nil {
+			return discarded, err
+		}
+	}
+
+	return discarded, nil
+}
+
+// Split data written in sequence into literal and zero run operations,
+// zero runs of a block or more, or across writes, become zero operations
+type zeroSplitter struct {
+	stream    *streamer
+	blockSize int
+	zeros     int64 // Pending zero bytes
+}
+
+// Add zero bytes, eg. a hole
+func (z *zeroSplitter) zero(size int64) {
+	z.zeros += size
+}
+
+func (z *zeroSplitter) write(data []byte) {
+	for len(data) > 0 {
+		// Leading zeros join pending run
+		i := 0
+		for i < len(data) && data[i] == 0 {
+			i++
+		}
+
+		z.zeros += int64(i)
+		data = data[i:]
+		if len(data) == 0 {
+			return
+		}
+
+		z.flush()
+		// Literal until a zero run of a block or one reaching end of data,
+		// the run is left for next loop
+		end, run := 0, 0
+		for ; end < len(data) && run < z.blockSize; end++ {
+			run++
+			if data[end] != 0 {
+				run = 0
+			}
+		}
+
+		z.stream.literal(data[:end-run])
+		data = data[end-run:]
+	}
+}
+
+// Add pending zero run
+func (z *zeroSplitter) flush() {
+	if z.zeros >= int64(z.blockSize) {
+		z.stream.zero(z.zeros)
+	} else if z.zeros > 0 {
+		z.stream.literal(make([]byte, z.zeros))
+	}
+
+	z.zeros = 0
+}
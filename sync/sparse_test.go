@@ -0,0 +1,171 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Writer recording zero runs left as holes
+type holeBuffer struct {
+	bytes.Buffer
+	holes int64
+}
+
+func (h *holeBuffer) Hole(size int64) error {
+	h.holes += size
+	h.Write(make([]byte, size))
+	return nil
+}
+
+// Return basis and target sharing data, target with zero runs of every alignment
+func sparseData() ([]byte, []byte, []Range) {
+	random := rand.New(rand.NewSource(1))
+	basis := make([]byte, 1<<14)
+	random.Read(basis)
+
+	target := append([]byte{}, basis[:3000]...)
+	hole := Range{int64(len(target)), int64(len(target)) + 5000}
+	target = append(target, make([]byte, 5000)...)
+	target = append(target, basis[3000:9000]...)
+	target = append(target, make([]byte, 1000)...)
+	target = append(target, basis[9000:]...)
+	target = append(target, make([]byte, 700)...)
+	return basis, target, []Range{hole}
+}
+
+func TestZeroRuns(t *testing.T) {
+	basis, target, holes := sparseData()
+	cases := map[string][]Option{
+		"plain":       nil,
+		"holes":       {WithHoles(holes)},
+		"checkpoints": {WithHoles(holes), WithCheckpoint(1 << 10)},
+		"compression": {WithCompression(CompressDeflate)},
+		"basis":       {WithBasis(bytes.NewReader(basis)), WithEditScripts()},
+	}
+
+	for name, options := range cases {
+		// Holes are target ones, basis is signed dense
+		sig := New(1 << 8).BuildSigTable(bufio.NewReader(bytes.NewReader(basis)))
+		sync := New(0, options...)
+		delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(target)))
+		if !reflect.DeepEqual(delta, sync.DiffBytes(sig, target)) {
+			t.Errorf("Expected %s slice delta equal to reader one", name)
+		}
+
+		// Every zero run of a block or more is a zero operation
+		if stats := NewStats(sig, delta); stats.ZeroBytes < 6000 || stats.LiteralBytes > 1500 {
+			t.Errorf("Expected %s zero runs sent as zero bytes, got %d zero and %d literal", name, stats.ZeroBytes, stats.LiteralBytes)
+		}
+
+		var out holeBuffer
+		if err := Patch(bytes.NewReader(basis), delta, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+			t.Errorf("Expected %s delta patched, got %v", name, err)
+		}
+
+		if out.holes < 6000 {
+			t.Errorf("Expected %s zero runs left as holes, got %d bytes", name, out.holes)
+		}
+
+		var dense bytes.Buffer
+		if err := Patch(bytes.NewReader(basis), delta, &dense); err != nil || !bytes.Equal(dense.Bytes(), target) {
+			t.Errorf("Expected %s delta patched with zeros written, got %v", name, err)
+		}
+	}
+}
+
+func TestZeroRunsMerged(t *testing.T) {
+	_, target, holes := sparseData()
+	sync := New(1<<8, WithHoles(holes))
+	delta := sync.DiffBytes(Signature{}, target)
+
+	var zeros []int64
+	for _, op := range delta.Ops {
+		if op.Type == OpZero {
+			zeros = append(zeros, op.Size)
+		}
+	}
+
+	// Hole is joined to the zero block before it, zero runs are cut to whole blocks
+	if !reflect.DeepEqual(zeros, []int64{5000, 768, 512}) {
+		t.Errorf("Expected hole and aligned zero blocks as zero runs, got %v", zeros)
+	}
+}
+
+func TestHoleSignature(t *testing.T) {
+	_, target, holes := sparseData()
+	for _, size := range []int{1 << 6, 1 << 8, 1000} {
+		expected := New(size).BuildSigTable(bufio.NewReader(bytes.NewReader(target)))
+		sync := New(size, WithHoles(holes))
+		if sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(target))); !reflect.DeepEqual(sig, expected) {
+			t.Errorf("Expected signature skipping holes equal to dense one for block size %d", size)
+		}
+
+		if sig := sync.BuildSigTableBytes(target); !reflect.DeepEqual(sig, expected) {
+			t.Errorf("Expected slice signature skipping holes equal to dense one for block size %d", size)
+		}
+	}
+}
+
+func TestComposeZeroRuns(t *testing.T) {
+	basis, target, _ := sparseData()
+	final := append([]byte{}, target...)
+	copy(final[4000:], "inside zero run")
+
+	sync := New(1 << 8)
+	first := sync.Diff(sync.BuildSigTableBytes(basis), bufio.NewReader(bytes.NewReader(target)))
+	second := sync.Diff(sync.BuildSigTableBytes(target), bufio.NewReader(bytes.NewReader(final)))
+	composed, err := Compose(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), composed, &out); err != nil || !bytes.Equal(out.Bytes(), final) {
+		t.Errorf("Expected composed delta patched, got %v", err)
+	}
+}
+
+func TestRefineZeroRuns(t *testing.T) {
+	basis, target, _ := sparseData()
+	coarse, fine := New(1<<10), New(1<<6)
+	coarseSig := coarse.BuildSigTableBytes(basis)
+	delta := coarse.DiffBytes(coarseSig, target)
+
+	fineSig, err := fine.BuildRangeSig(bytes.NewReader(basis), Unmatched(coarseSig, delta))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refined, err := coarse.Refine(delta, fineSig, bufio.NewReader(bytes.NewReader(target)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := Patch(bytes.NewReader(basis), refined, &out); err != nil || !bytes.Equal(out.Bytes(), target) {
+		t.Errorf("Expected refined delta with zero runs patched, got %v", err)
+	}
+}
+
+func TestZeroSplitter(t *testing.T) {
+	stream := newStreamer(0, CompressNone)
+	z := &zeroSplitter{stream: stream, blockSize: 1 << 8}
+	// Zero run across writes, short zero run kept in literal
+	z.write(append([]byte("head"), make([]byte, 200)...))
+	z.write(append(make([]byte, 100), "middle"...))
+	z.write(append(make([]byte, 10), "tail"...))
+	z.flush()
+
+	var types []OpType
+	for _, op := range stream.done().Ops {
+		types = append(types, op.Type)
+	}
+
+	expected := []OpType{OpLiteral, OpZero, OpLiteral, OpLiteral, OpLiteral}
+	if !reflect.DeepEqual(types, expected) || stream.written != 4+300+6+10+4 {
+		t.Errorf("Expected zero run split out of literals, got %v", types)
+	}
+}
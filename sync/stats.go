@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+)
+
+// Stats summarize delta efficiency, useful to tune block size
+// and to compare delta size against sending the whole file.
+type Stats struct {
+	Blocks         int           // Blocks in signature
+	Matched        int           // Signature blocks copied at least once
+	Missing        int           // Signature blocks not found in target
+	CopyBytes      int64         // Target bytes rebuilt from source
+	LiteralBytes   int64         // Target bytes sent as new data
+	ZeroBytes      int64         // Target bytes sent as zero runs
+	DeltaBytes     int64         // Data bytes stored in delta, after compression
+	FalsePositives int           // Weak checksum hits rejected by strong checksum
+	Hashing        time.Duration // Time spent in strong checksums while matching
+	Elapsed        time.Duration // Time spent calculating delta
+}
+
+// Return target size rebuilt by delta
+func (s Stats) TargetBytes() int64 {
+	return s.CopyBytes + s.LiteralBytes + s.ZeroBytes
+}
+
+// Return delta data size versus sending the whole target, eg. 0.1 = 10%
+func (s Stats) Ratio() float64 {
+	if s.TargetBytes() == 0 {
+		return 0
+	}
+
+	return float64(s.DeltaBytes) / float64(s.TargetBytes())
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf(
+		"blocks: %d\nmatched blocks: %d\nmissing blocks: %d\n"+
+			"copy bytes: %d\nliteral bytes: %d\nzero bytes: %d\ndelta bytes: %d\nratio: %.2f%%\n"+
+			"false positives: %d\nhashing: %s\nelapsed: %s",
+		s.Blocks, s.Matched, s.Missing,
+		s.CopyBytes, s.LiteralBytes, s.ZeroBytes, s.DeltaBytes, s.Ratio()*100,
+		s.FalsePositives, s.Hashing, s.Elapsed,
+	)
+}
+
+// Matching counters collected while calculating delta,
+// every event is also reported to metrics
+type probe struct {
+	falsePositives int
+	hashing        time.Duration
+	metrics        []Metrics
+}
+
+// Return probe reporting to Sync counters and metrics
+func (s *Sync) probe() *probe {
+	p := &probe{metrics: []Metrics{s.counters}}
+	if s.metrics != nil {
+		p.metrics = append(p.metrics, s.metrics)
+	}
+
+	return p
+}
+
+// Calculate stats for delta against signature blocks.
+// Matching counters and timing are only available from DiffStats.
+func NewStats(sig Signature, delta Stream) Stats {
+	stats := Stats{Blocks: len(sig.Tables)}
+	matched := make(map[int64]bool)
+
+	for _, op := range delta.Ops {
+		switch op.Type {
+		case OpCopy:
+			matched[op.Index] = true
+			stats.CopyBytes += op.Len()
+		case OpLiteral:
+			stats.LiteralBytes += op.Len()
+			stats.DeltaBytes += int64(len(op.Lit))
+		case OpZero:
+			stats.ZeroBytes += op.Len()
+		case OpEdit:
+			for _, edit := range op.Edits {
+				stats.CopyBytes += int64(edit.Keep)
+				stats.LiteralBytes += int64(len(edit.Insert))
+				stats.DeltaBytes += int64(len(edit.Insert))
+			}
+		case OpAdd:
+			stats.CopyBytes += op.Len()
+			stats.DeltaBytes += int64(len(op.Lit))
+		}
+	}
+
+	stats.Matched = len(matched)
+	stats.Missing = stats.Blocks - stats.Matched
+	return stats
+}
+
+// Calculate delta like Diff, returning stats for it
+func (s *Sync) DiffStats(sig Signature, reader *bufio.Reader) (Stream, Stats) {
+	counters := s.probe()
+	start := time.Now()
+	delta := s.diff(sig, reader, counters)
+	elapsed := time.Since(start)
+
+	stats := NewStats(sig, delta)
+	stats.FalsePositives = counters.falsePositives
+	stats.Hashing = counters.hashing
+	stats.Elapsed = elapsed
+	return delta, stats
+}
+
+// Seek block position counting weak checksum false positives and strong checksum time
+func (p *probe) seek(idx Indexes, wk uint32, b, seed []byte) int {
+	subfield, found := idx[wk]
+	if !found {
+		return -1
+	}
+
+	for _, m := range p.metrics {
+		m.WeakHit()
+	}
+
+	start := time.Now()
+	st := keyedStrong(seed, b)
+	p.hashing += time.Since(start)
+	if index, ok := subfield[st]; ok {
+		for _, m := range p.metrics {
+			m.StrongMatch()
+		}
+
+		return index
+	}
+
+	p.falsePositives++
+	for _, m := range p.metrics {
+		m.FalsePositive()
+	}
+
+	return -1
+}
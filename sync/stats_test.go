@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
+	b := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash")
+
+	sync := New(1 << 4)
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta, stats := sync.DiffStats(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	if stats.TargetBytes() != int64(len(b)) {
+		t.Errorf("Expected stats cover %d target bytes, got %d", len(b), stats.TargetBytes())
+	}
+
+	if stats.Blocks != 6 || stats.Matched+stats.Missing != stats.Blocks {
+		t.Errorf("Expected 6 blocks split into matched and missing, got %+v", stats)
+	}
+
+	if stats.Missing != 1 || stats.LiteralBytes != 11 {
+		t.Errorf("Expected block 2 missing and sent as literal, got %+v", stats)
+	}
+
+	if stats.Ratio() <= 0 || stats.Ratio() >= 1 {
+		t.Errorf("Expected delta smaller than target, got ratio %f", stats.Ratio())
+	}
+
+	// Structural stats don't depend on matching counters
+	plain := NewStats(sig, delta)
+	plain.FalsePositives, plain.Hashing, plain.Elapsed = stats.FalsePositives, stats.Hashing, stats.Elapsed
+	if plain != stats {
+		t.Errorf("Expected stats from stream %+v equal to %+v", plain, stats)
+	}
+}
+
+func TestStatsFalsePositives(t *testing.T) {
+	// "caac" = "bbbb" + (1, -1, -1, 1) keep adler32 sums, not SHA-1 ones
+	a := []byte("bbbb")
+	b := []byte("caac")
+	if weak(a) != weak(b) {
+		t.Fatal("Expected crafted blocks with same weak checksum")
+	}
+
+	sync := New(4)
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	_, stats := sync.DiffStats(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	if stats.FalsePositives != 1 {
+		t.Errorf("Expected 1 false positive, got %d", stats.FalsePositives)
+	}
+
+	if stats.Matched != 0 || stats.LiteralBytes != 4 {
+		t.Errorf("Expected weak collision sent as literal, got %+v", stats)
+	}
+}
@@ -5,8 +5,10 @@ package sync
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/sha1"
 	"encoding/hex"
+	"hash"
 	"io"
 )
 
@@ -25,17 +27,17 @@ Bytes store block differences
 	No literal matches = any match found by position range in block to copy eg. Block missing && Start > 0 && Offset > 0
 */
 type Bytes struct {
-	Offset  int    // End of diff position in block
-	Start   int    // Start of diff position in block
+	Offset  int64  // End of diff position in block
+	Start   int64  // Start of diff position in block
 	Missing bool   // true if Block not found
 	Lit     []byte // Literal bytes to replace in delta
 }
 
 // Store delta matches
-type Delta map[int]Bytes
+type Delta map[int64]Bytes
 
 // Add new match to delta table
-func (d Delta) Add(index int, b Bytes) {
+func (d Delta) Add(index int64, b Bytes) {
 	d[index] = b
 }
 
@@ -45,66 +47,180 @@ type Table struct {
 	Strong string
 }
 
+// Signature keep the block tables for a file
+// plus the whole-file strong checksum used to verify patched output
+type Signature struct {
+	BlockSize int     // Block size used to build tables
+	Seed      []byte  // Block checksums seed, nil if not seeded
+	Checksum  string  // Strong checksum of the complete file
+	Tables    []Table // Weak + strong checksum for each block
+}
+
 type Sync struct {
-	blockSize int
+	blockSize   int
+	fileSize    int64
+	basis       io.ReaderAt
+	checkpoint  int
+	compression Compression
+	edits       bool
+	bsdiff      bool
+	counters    *counters
+	metrics     Metrics
+	seed        []byte
+	table       *table  // Weak checksum table derived from seed
+	holes       []Range // Zero ranges of input
+	dense       bool    // Zero runs are not turned into zero operations
 }
 
 // Factory function
-func New(size int) *Sync {
-	return &Sync{
-		blockSize: size,
+// Zero size pick block size using AutoBlockSize, see WithFileSize.
+func New(size int, options ...Option) *Sync {
+	s := &Sync{
+		blockSize:  size,
+		checkpoint: CheckpointInterval,
+		counters:   &counters{},
+	}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Return copy of sync using block size recorded in signature,
+// or auto block size if none is set
+func (s *Sync) sized(sig Signature) *Sync {
+	sized := *s
+	if sig.BlockSize > 0 {
+		sized.blockSize = sig.BlockSize
+	}
+
+	if sized.blockSize == 0 {
+		sized.blockSize = AutoBlockSize(s.fileSize)
+	}
+
+	return &sized
+}
+
+// Return copy of sync keyed with seed recorded in signature
+func (s *Sync) seeded(seed []byte) *Sync {
+	if bytes.Equal(seed, s.seed) {
+		return s
 	}
+
+	seeded := *s
+	seeded.seed = seed
+	seeded.table = seedTable(seed)
+	return &seeded
 }
 
 // Calc and return strong md5 checksum
 func strong(block []byte) string {
+	return keyedStrong(nil, block)
+}
+
+// Calc and return strong sha1 checksum prefixed by seed
+func keyedStrong(seed, block []byte) string {
 	strong := sha1.New()
+	strong.Write(seed)
 	strong.Write(block)
 	return hex.EncodeToString(strong.Sum(nil))
 }
 
+// Return the hex encoded sum for a running strong hash
+func sum(h hash.Hash) string {
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // Calc and return weak adler32 checksum
 func weak(block []byte) uint32 {
 	weak := NewAdler32()
 	return weak.Write(block).Sum()
 }
 
+// Return new rolling checksum keyed with sync seed
+func (s *Sync) rolling() Adler32 {
+	rolling := NewAdler32()
+	rolling.table = s.table
+	return rolling
+}
+
+// Return position of block in file
+func (s *Sync) position(index int) int64 {
+	return int64(index) * int64(s.blockSize)
+}
+
 // Return new calculated range position in block diffs
 func (s *Sync) block(index int, literalMatches []byte) Bytes {
 	return Bytes{
-		Start:  (index * s.blockSize),                 // Block change start
-		Offset: ((index * s.blockSize) + s.blockSize), // Block change endwhereas it could be copied-on-write to a new data structure
-		Lit:    literalMatches,                        // Store literal matches
+		Start:  s.position(index),                      // Block change start
+		Offset: s.position(index) + int64(s.blockSize), // Block change endwhereas it could be copied-on-write to a new data structure
+		Lit:    literalMatches,                         // Store literal matches
 	}
 }
 
 // Fill signature from blocks using
 // Weak + Strong hash table to avoid collisions.
 // Hash table improve performance for mapping search using strong calc only if weak is found
-func (s *Sync) BuildSigTable(reader *bufio.Reader) []Table {
+func (s *Sync) BuildSigTable(reader *bufio.Reader) Signature {
+	s = s.sized(Signature{})
 	// Read chunks from file
 	block := make([]byte, s.blockSize)
 	// Declares Table nil slice
 	var signatures []Table
+	// Whole file checksum
+	checksum := sha1.New()
+	// Blocks inside holes share the zero block table
+	var zero *Table
+	var position int64
 
 	for {
+		// Skip blocks inside holes without reading them
+		if s.hole(position) >= int64(s.blockSize) {
+			skipped, _ := discard(reader, int64(s.blockSize))
+			if skipped == 0 {
+				break
+			}
+
+			if zero == nil || skipped < int64(s.blockSize) {
+				table := s.zeroTable(int(skipped))
+				zero = &table
+			}
+
+			zeros(checksum, skipped)
+			signatures = append(signatures, *zero)
+			position += skipped
+			continue
+		}
+
 		// Add chunks to buffer
-		bytesRead, err := reader.Read(block)
+		// ReadFull avoid short blocks when the reader buffer get drained
+		bytesRead, _ := io.ReadFull(reader, block)
 		// Stop if not bytes read or end to file
-		if bytesRead == 0 || err == io.EOF {
+		if bytesRead == 0 {
 			break
 		}
 
+		// Last block could be smaller than block size
+		chunk := block[:bytesRead]
+		position += int64(bytesRead)
+		checksum.Write(chunk)
 		// Weak and strong checksum
 		// https://rsync.samba.org/tech_report/node3.
-		weak := weak(block)
-		strong := strong(block)
+		weak := s.rolling().Write(chunk).Sum()
+		strong := keyedStrong(s.seed, chunk)
 		// Keep signatures while get written
 		table := Table{Weak: weak, Strong: strong}
 		signatures = append(signatures, table)
 	}
 
-	return signatures
+	return Signature{
+		BlockSize: s.blockSize,
+		Seed:      s.seed,
+		Checksum:  sum(checksum),
+		Tables:    signatures,
+	}
 }
 
 // Fill tables indexes to match block position and return indexes:
@@ -113,7 +229,17 @@ func (*Sync) BuildIndexes(signatures []Table) Indexes {
 	indexes := make(Indexes) // Build Indexes
 	// Keep signatures in memory while get processed
 	for i, check := range signatures {
-		indexes[check.Weak] = map[string]int{check.Strong: i}
+		// Blocks out of signature ranges have no tables, see BuildRangeSig
+		if check.Strong == "" {
+			continue
+		}
+
+		// Blocks could share weak checksum, keep every strong one
+		if _, ok := indexes[check.Weak]; !ok {
+			indexes[check.Weak] = make(map[string]int)
+		}
+
+		indexes[check.Weak][check.Strong] = i
 	}
 
 	return indexes
@@ -121,26 +247,19 @@ func (*Sync) BuildIndexes(signatures []Table) Indexes {
 
 // Based on weak + string map searching for block position
 // in indexes and return block number or -1 if not found.
-func (*Sync) Seek(idx Indexes, wk uint32, b []byte) int {
-	// Check if weaksum exists in indexes table
-	if subfield, found := idx[wk]; found {
-		st := strong(b) // Calc strong hash until weak found
-		if _, ok := subfield[st]; ok {
-			return subfield[st]
-		}
-	}
-
-	return -1
+// Weak hits, strong matches and false positives are counted, see Counters.
+func (s *Sync) Seek(idx Indexes, wk uint32, b []byte) int {
+	return s.probe().seek(idx, wk, b, s.seed)
 }
 
 // Check if any block get removed and return the cleaned/amplified matches copy with missing blocks
 func (s *Sync) IntegrityCheck(sig []Table, matches Delta) Delta {
 	for i := range sig {
-		if _, ok := matches[i]; !ok {
-			matches[i] = Bytes{
-				Missing: true,                            // Block not found
-				Start:   i * s.blockSize,                 // Start range of block to copy
-				Offset:  (i * s.blockSize) + s.blockSize, // End block to copy
+		if _, ok := matches[int64(i)]; !ok {
+			matches[int64(i)] = Bytes{
+				Missing: true,                               // Block not found
+				Start:   s.position(i),                      // Start range of block to copy
+				Offset:  s.position(i) + int64(s.blockSize), // End block to copy
 			}
 		}
 	}
@@ -148,34 +267,76 @@ func (s *Sync) IntegrityCheck(sig []Table, matches Delta) Delta {
 	return matches
 }
 
-// Calculate "delta" and return match diffs.
-// Return map "Bytes" matches, each Byte keep position and literal
-// diff matches for block and the map key keep the block position.
-func (s *Sync) Delta(sig []Table, reader *bufio.Reader) Delta {
+// Calculate the ordered operations needed to rebuild "target" from the signed "source".
+// Return Stream with copy operations for matched blocks and literal operations for
+// any bytes not found in source, plus the strong checksum of the whole target
+// and checkpoints to resume an interrupted patch.
+func (s *Sync) Diff(sig Signature, reader *bufio.Reader) Stream {
+	return s.diff(sig, reader, s.probe())
+}
+
+// Calculate delta collecting matching counters in probe
+func (s *Sync) diff(sig Signature, reader *bufio.Reader, counters *probe) Stream {
+	s = s.sized(sig).seeded(sig.Seed) // Never guess block size or seed, use the signature ones
+	if s.bsdiff && s.basis != nil {
+		if basis, err := readAll(s.basis); err == nil {
+			target, _ := io.ReadAll(reader)
+			return s.binaryDiff(basis, target)
+		}
+	}
+
 	// Weak checksum adler32
-	weak := NewAdler32()
-	// Delta matches
-	delta := make(Delta)
+	weak := s.rolling()
 	// Indexes for block position
-	indexes := s.BuildIndexes(sig)
+	indexes := s.BuildIndexes(sig.Tables)
 	// Literal matches keep literal diff bytes stored
 	var tmpLitMatches []byte
+	// Collect operations in order, computing target checksum and checkpoints
+	stream := newStreamer(s.checkpoint, s.compression)
+	// Target bytes read and trailing zero bytes in window
+	var position int64
+	zeros := 0
 
 	// Keep tracking changes
 	for {
-		// Get byte from reader
-		// eg. reader = [abcd], byte = a...
-		c, err := reader.ReadByte()
-		// If reach end of file or error trying to get byte
-		if err == io.EOF || err != nil {
-			break
-		}
+		// Fill the whole window at once at start and right after a match
+		if weak.Count() == 0 {
+			// Holes are zero runs, skip them without reading
+			if hole := s.hole(position); hole > 0 && !s.dense {
+				skipped, err := discard(reader, hole)
+				stream.zero(skipped)
+				position += skipped
+				if err != nil {
+					break
+				}
 
-		// Add new el to checksum
-		weak = weak.RollIn(c)
-		// Keep moving forward if not data ready
-		if weak.Count() < s.blockSize {
-			continue
+				continue
+			}
+
+			window := make([]byte, s.blockSize)
+			read, _ := io.ReadFull(reader, window)
+			weak = weak.fill(window[:read])
+			position += int64(read)
+			zeros = trailingZeros(window[:read])
+			if read < s.blockSize {
+				break
+			}
+		} else {
+			// Get byte from reader
+			// eg. reader = [abcd], byte = a...
+			c, err := reader.ReadByte()
+			// If reach end of file or error trying to get byte
+			if err == io.EOF || err != nil {
+				break
+			}
+
+			// Add new el to checksum
+			weak = weak.RollIn(c)
+			position++
+			zeros++
+			if c != 0 {
+				zeros = 0
+			}
 		}
 
 		// Start moving window over data
@@ -189,23 +350,122 @@ func (s *Sync) Delta(sig []Table, reader *bufio.Reader) Delta {
 			tmpLitMatches = append(tmpLitMatches, removed)
 		}
 
+		// Whole window is zero, add it as zero run instead of looking for a block
+		if zeros >= s.blockSize && !s.dense {
+			if s.basis != nil {
+				tmpLitMatches = s.extend(stream, tmpLitMatches)
+			}
+
+			stream.literal(tmpLitMatches)
+			stream.zero(int64(s.blockSize))
+			tmpLitMatches = nil
+			weak = s.rolling()
+			zeros = 0
+			continue
+		}
+
 		// Calc checksum based on rolling hash
 		// Check if weak and strong match in checksums position based signatures
-		index := s.Seek(indexes, weak.Sum(), weak.Window())
+		index := counters.seek(indexes, weak.Sum(), weak.Window(), s.seed)
 		if ^index != 0 { // match found
-			// Generate new block with calculated range positions for diffing
-			newBlock := s.block(index, tmpLitMatches)
-			delta.Add(index, newBlock) // Add new block to delta matches
-			// Clear garbage collectable
-			tmpLitMatches = tmpLitMatches[:0] // clear tmp literal matches
-			weak = NewAdler32()               // replace weak adler object
+			// Generate new copy operation with calculated range positions for block
+			block := s.block(index, nil)
+			data := weak.Window()
+
+			// Extend matches byte by byte around literal, at most a block less one byte
+			// so every block found keep its own copy operation
+			if s.basis != nil {
+				tmpLitMatches = s.extend(stream, tmpLitMatches)
+				backward := extendBackward(s.basis, tmpLitMatches, block.Start, s.blockSize-1)
+				data = append(append([]byte{}, tmpLitMatches[len(tmpLitMatches)-backward:]...), data...)
+				tmpLitMatches = tmpLitMatches[:len(tmpLitMatches)-backward]
+				block.Start -= int64(backward)
+
+			}
+
+			// Flush pending literal matches before copy
+			s.flush(stream, tmpLitMatches, block.Start)
+			stream.copy(int64(index), block.Start, block.Offset, data)
+			// Start a new literal buffer, the previous one is owned by the op
+			tmpLitMatches = nil
+			weak = s.rolling() // replace weak adler object
+		}
+
+	}
+
+	// Anything left in the window or pending literal is new data
+	tmpLitMatches = append(tmpLitMatches, weak.Window()...)
+	if s.basis != nil {
+		tmpLitMatches = s.extend(stream, tmpLitMatches)
+	}
+
+	stream.literal(tmpLitMatches)
+	return stream.done()
+}
+
+// Extend last copy operation forward with leading literal bytes matching basis.
+// Return remaining literal bytes.
+func (s *Sync) extend(stream *streamer, lit []byte) []byte {
+	last, ok := stream.lastCopy()
+	if !ok {
+		return lit
+	}
+
+	forward := extendForward(s.basis, lit, last.Offset, s.blockSize-1)
+	stream.extend(lit[:forward])
+	return lit[forward:]
+}
+
+// Add pending literal before a copy starting at basis position next.
+// If edit scripts are enabled and the literal replace a basis range, add
+// the edit script against that range when it is smaller than the literal.
+func (s *Sync) flush(stream *streamer, lit []byte, next int64) {
+	start, ok := stream.end()
+	if s.edits && s.basis != nil && ok && start < next && len(lit) > 0 &&
+		len(lit) <= maxEditSize && next-start <= maxEditSize {
+		replaced := make([]byte, next-start)
+		read, _ := s.basis.ReadAt(replaced, start)
+		if edits := diffBytes(replaced[:read], lit); edits != nil && editSize(start, start+int64(read), edits) < literalOpSize(lit) {
+			stream.edit(start, start+int64(read), edits, lit)
+			return
+		}
+	}
+
+	stream.literal(lit)
+}
+
+// Calculate "delta" and return match diffs.
+// Return map "Bytes" matches, each Byte keep position and literal
+// diff matches for block and the map key keep the block position.
+func (s *Sync) Delta(sig Signature, reader *bufio.Reader) Delta {
+	s = s.sized(sig)
+	// Delta table keep raw literals, extended matches would hide bytes from blocks
+	raw := *s
+	raw.compression = CompressNone
+	raw.basis = nil
+	raw.edits = false
+	raw.bsdiff = false
+	raw.dense = true
+	// Delta matches
+	delta := make(Delta)
+	// Literal matches keep literal diff bytes stored
+	var tmpLitMatches []byte
+
+	for _, op := range raw.Diff(sig, reader).Ops {
+		if op.Type == OpLiteral {
+			tmpLitMatches = append(tmpLitMatches, op.Lit...)
+			continue
 		}
 
+		// Generate new block with calculated range positions for diffing
+		newBlock := s.block(int(op.Index), tmpLitMatches)
+		delta.Add(op.Index, newBlock) // Add new block to delta matches
+		tmpLitMatches = nil           // literal matches are owned by block now
 	}
 
 	// Missing blocks?
 	// Finally check the blocks integrity
 	// Return cleaned/amplified copy for delta matches
-	return s.IntegrityCheck(sig, delta)
+	return s.IntegrityCheck(sig.Tables, delta)
 
 }
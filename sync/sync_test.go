@@ -34,7 +34,7 @@ Signatures:
 
 **/
 
-func CalculateDelta(a []byte, b []byte) map[int]Bytes {
+func CalculateDelta(a []byte, b []byte) map[int64]Bytes {
 
 	sync := New(1 << 4) // 16 bytes
 
@@ -51,7 +51,7 @@ func CalculateDelta(a []byte, b []byte) map[int]Bytes {
 	return sync.Delta(sig, bufioB)
 }
 
-func CheckMatch(delta map[int]Bytes, expected map[int][]byte, t *testing.T) {
+func CheckMatch(delta map[int64]Bytes, expected map[int64][]byte, t *testing.T) {
 
 	for i := range expected {
 		// Index not matched in delta
@@ -70,7 +70,7 @@ func CheckMatch(delta map[int]Bytes, expected map[int][]byte, t *testing.T) {
 func TestDetectChunkChange(t *testing.T) {
 	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
 	b := []byte("i here guys how are you doing this is a mall test chunk split and rolling hash")
-	expect := map[int][]byte{
+	expect := map[int64][]byte{
 		1: []byte("i here guys h"),               // Match first block change
 		4: []byte(" this is a mall test chunk "), // Match block 4 changed
 
@@ -91,7 +91,7 @@ func TestSeekMatchBlock(t *testing.T) {
 	weakSum := uint32(231277338)
 	sig := sync.BuildSigTable(bufioA)
 
-	indexes := sync.BuildIndexes(sig)
+	indexes := sync.BuildIndexes(sig.Tables)
 	index := sync.Seek(indexes, weakSum, []byte("rld this"))
 
 	if index != 1 {
@@ -107,9 +107,9 @@ func TestIndexTable(t *testing.T) {
 
 	// For each block slice from file
 	signatures := sync.BuildSigTable(bufioA)
-	indexes := sync.BuildIndexes(signatures)
+	indexes := sync.BuildIndexes(signatures.Tables)
 
-	for i, check := range signatures {
+	for i, check := range signatures.Tables {
 		weak := check.Weak
 		strong := check.Strong
 		if indexes[weak][strong] != i {
@@ -119,10 +119,26 @@ func TestIndexTable(t *testing.T) {
 
 }
 
+func TestSignatureChecksum(t *testing.T) {
+	a := []byte("hello world this is a test for my whole file checksum")
+	sync := New(1 << 3) // 8 bytes
+
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	if sig.Checksum != strong(a) {
+		t.Errorf("Expected signature checksum equal to strong hash of whole file")
+	}
+
+	// Last block is smaller than block size
+	last := sig.Tables[len(sig.Tables)-1]
+	if last.Strong != strong([]byte("checksum")[3:]) {
+		t.Errorf("Expected last block signature computed over remaining bytes only")
+	}
+}
+
 func TestDetectChunkAdd(t *testing.T) {
 	a := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
 	b := []byte("i am here guys how are you doingadded this is a small test for chunk split and rolling hash")
-	expect := map[int][]byte{
+	expect := map[int64][]byte{
 		2: []byte("added"), // Match blocks 2 changed
 
 	}
@@ -157,8 +173,8 @@ func TestDetectChunkRemoval(t *testing.T) {
 func TestDetectChunkShifted(t *testing.T) {
 	o := []byte("i am here guys how are you doing this is a small test for chunk split and rolling hash")
 	c := []byte("i am here guys   how are you doing    test for chunk split and rolling hash")
-	expect := map[int][]byte{
-		1: []byte("   m here guys   h"), // Match first block change
+	expect := map[int64][]byte{
+		1: []byte("i am here guys   h"), // Match first block change
 		3: []byte("   "),                // Match third block change
 	}
 
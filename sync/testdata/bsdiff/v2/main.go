@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+func greeting(name string) string {
+	return strings.Repeat("-", 3) + " rolling-sync fixture " + name + "\n"
+}
+
+func main() {
+	os.Stdout.WriteString(greeting("v2"))
+}
@@ -0,0 +1,85 @@
+package sync
+
+import "io"
+
+// Target range rebuilt by a copy operation
+type span struct {
+	start, end int64 // Target range
+	source     int64 // Source position for start
+}
+
+// Derive signature of delta target from source signature and delta, so the next
+// sync cycle don't need to hash the whole target. Tables of blocks copied whole
+// from a source block are reused, the rest are rehashed reading target.
+func (s *Sync) UpdateSignature(sig Signature, delta Stream, target io.ReaderAt) (Signature, error) {
+	s = s.sized(sig).seeded(sig.Seed)
+	var spans []span
+	var size int64
+	for _, op := range delta.Ops {
+		if op.Type == OpCopy {
+			// Merge copies of contiguous source ranges
+			last := len(spans) - 1
+			if last >= 0 && spans[last].end == size && spans[last].source+size-spans[last].start == op.Start {
+				spans[last].end += op.Len()
+			} else {
+				spans = append(spans, span{start: size, end: size + op.Len(), source: op.Start})
+			}
+		}
+
+		size += op.Len()
+	}
+
+	updated := Signature{BlockSize: s.blockSize, Seed: s.seed, Checksum: delta.Checksum}
+	block := make([]byte, s.blockSize)
+	for start := int64(0); start < size; start += int64(s.blockSize) {
+		end := start + int64(s.blockSize)
+		if end > size {
+			end = size
+		}
+
+		// Skip copies ending before block
+		for len(spans) > 0 && spans[0].end <= start {
+			spans = spans[1:]
+		}
+
+		if table, ok := s.copied(sig, spans, start, end); ok {
+			updated.Tables = append(updated.Tables, table)
+			continue
+		}
+
+		// Only the last block could be read short, at target end
+		n, err := target.ReadAt(block[:end-start], start)
+		if err != nil && err != io.EOF {
+			return updated, err
+		}
+
+		if n < int(end-start) && (err != io.EOF || end != size) {
+			return updated, io.ErrUnexpectedEOF
+		}
+
+		chunk := block[:n]
+
+		updated.Tables = append(updated.Tables, Table{
+			Weak:   s.rolling().Write(chunk).Sum(),
+			Strong: keyedStrong(s.seed, chunk),
+		})
+	}
+
+	return updated, nil
+}
+
+// Return source table for target block if it is a whole source block copy.
+// Source last block could be shorter than block size, so it is never reused.
+func (s *Sync) copied(sig Signature, spans []span, start, end int64) (Table, bool) {
+	if len(spans) == 0 || spans[0].start > start || spans[0].end < end || end-start != int64(s.blockSize) {
+		return Table{}, false
+	}
+
+	source := spans[0].source + start - spans[0].start
+	index := source / int64(s.blockSize)
+	if source%int64(s.blockSize) != 0 || index >= int64(len(sig.Tables)-1) {
+		return Table{}, false
+	}
+
+	return sig.Tables[index], true
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// ReaderAt counting bytes read
+type countingReader struct {
+	data []byte
+	read int
+}
+
+func (c *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytes.NewReader(c.data).ReadAt(p, off)
+	c.read += n
+	return n, err
+}
+
+// ReaderAt returning short reads without error
+type shortReader struct {
+	data []byte
+}
+
+func (s shortReader) ReadAt(p []byte, off int64) (int, error) {
+	n, _ := bytes.NewReader(s.data).ReadAt(p[:len(p)/2], off)
+	return n, nil
+}
+
+func TestUpdateSignature(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	a := make([]byte, 1<<14)
+	random.Read(a)
+	blockSize := 1 << 8
+
+	replaced := append([]byte{}, a...)
+	copy(replaced[5000:], "replaced")
+	inserted := append(append(append([]byte{}, a[:4096]...), bytes.Repeat([]byte("x"), blockSize)...), a[4096:]...)
+
+	cases := []struct {
+		name    string
+		target  []byte
+		options []Option
+		maxRead int // Bytes rehashed
+	}{
+		{"replace", replaced, nil, 2 * blockSize},
+		{"insert block", inserted, nil, 2 * blockSize},
+		{"insert", append(append(append([]byte{}, a[:100]...), "inserted"...), a[100:]...), nil, len(a) + 8},
+		{"remove tail", a[:len(a)-300], nil, blockSize},
+		{"append", append(append([]byte{}, a...), "appended"...), nil, 2 * blockSize},
+		{"seed", replaced, []Option{WithSeed([]byte("seed"))}, 2 * blockSize},
+		{"basis", replaced, []Option{WithBasis(bytes.NewReader(a))}, 3 * blockSize},
+		{"edits", replaced, []Option{WithBasis(bytes.NewReader(a)), WithEditScripts()}, 3 * blockSize},
+		{"bsdiff", replaced, []Option{WithBasis(bytes.NewReader(a)), WithBSDiff()}, len(replaced)},
+	}
+
+	for _, c := range cases {
+		sync := New(blockSize, c.options...)
+		sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+		delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(c.target)))
+
+		target := &countingReader{data: c.target}
+		updated, err := New(0).UpdateSignature(sig, delta, target)
+		if err != nil {
+			t.Fatalf("Expected %s signature updated, got %v", c.name, err)
+		}
+
+		expected := New(blockSize, c.options...).BuildSigTable(bufio.NewReader(bytes.NewReader(c.target)))
+		if !reflect.DeepEqual(updated, expected) {
+			t.Errorf("Expected %s updated signature equal to full signature", c.name)
+		}
+
+		if target.read > c.maxRead {
+			t.Errorf("Expected %s to rehash at most %d bytes, got %d", c.name, c.maxRead, target.read)
+		}
+	}
+}
+
+func TestUpdateSignatureShortRead(t *testing.T) {
+	a := bytes.Repeat([]byte("i am here guys how are you doing this is a small test for chunk split and rolling hash "), 8)
+	b := bytes.Replace(a, []byte("small"), []byte("big"), -1)
+
+	sync := New(1 << 4)
+	sig := sync.BuildSigTable(bufio.NewReader(bytes.NewReader(a)))
+	delta := sync.Diff(sig, bufio.NewReader(bytes.NewReader(b)))
+
+	if _, err := sync.UpdateSignature(sig, delta, shortReader{b}); err == nil {
+		t.Errorf("Expected error on short target read")
+	}
+
+	// Truncated target fail before its end
+	if _, err := sync.UpdateSignature(sig, delta, bytes.NewReader(b[:len(b)/2])); err == nil {
+		t.Errorf("Expected error on truncated target")
+	}
+}
@@ -0,0 +1,73 @@
+package sync
+
+import (
+	"bytes"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Dictionary id recorded in zstd frames, dictionary content is the shared one
+const zstdDictID = 1
+
+// Zstandard codec with raw dictionary as initial history
+type zstdCodec struct{}
+
+// Return zstd codec for a single stream
+func (zstdCodec) Session() Codec { return &zstdSession{} }
+
+func (zstdCodec) Compress(data, dict []byte) ([]byte, error) {
+	return new(zstdSession).Compress(data, dict)
+}
+
+func (zstdCodec) Decompress(data, dict []byte) ([]byte, error) {
+	return new(zstdSession).Decompress(data, dict)
+}
+
+// Zstd encoder and decoder of a stream, dictionary is only set when they
+// are built, so they are rebuilt only when dictionary changed since last use
+type zstdSession struct {
+	encoder     *zstd.Encoder
+	decoder     *zstd.Decoder
+	encoderDict []byte
+	decoderDict []byte
+}
+
+func (z *zstdSession) Compress(data, dict []byte) ([]byte, error) {
+	if z.encoder == nil || !bytes.Equal(z.encoderDict, dict) {
+		options := []zstd.EOption{zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedBestCompression)}
+		if len(dict) > 0 {
+			options = append(options, zstd.WithEncoderDictRaw(zstdDictID, dict))
+		}
+
+		encoder, err := zstd.NewWriter(nil, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		z.encoder, z.encoderDict = encoder, append(z.encoderDict[:0], dict...)
+	}
+
+	return z.encoder.EncodeAll(data, nil), nil
+}
+
+func (z *zstdSession) Decompress(data, dict []byte) ([]byte, error) {
+	if z.decoder == nil || !bytes.Equal(z.decoderDict, dict) {
+		options := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if len(dict) > 0 {
+			options = append(options, zstd.WithDecoderDictRaw(zstdDictID, dict))
+		}
+
+		decoder, err := zstd.NewReader(nil, options...)
+		if err != nil {
+			return nil, err
+		}
+
+		if z.decoder != nil {
+			z.decoder.Close()
+		}
+
+		z.decoder, z.decoderDict = decoder, append(z.decoderDict[:0], dict...)
+	}
+
+	return z.decoder.DecodeAll(data, nil)
+}